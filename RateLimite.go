@@ -1,13 +1,11 @@
 package main
 
 import (
-	"fmt"
 	"io"
-	"log"
 	"time"
 )
 
-func copyWithRateLimit(src io.Reader, dst io.Writer, rateLimit int64, total int64, filename string, logger *log.Logger, background bool) (int64, error) {
+func copyWithRateLimit(src io.Reader, dst io.Writer, rateLimit int64, bar *progress) (int64, error) {
 	var written int64
 
 	// Choose buffer size
@@ -23,8 +21,6 @@ func copyWithRateLimit(src io.Reader, dst io.Writer, rateLimit int64, total int6
 	}
 
 	buf := make([]byte, bufferSize)
-	startTime := time.Now()
-	lastUpdate := time.Now()
 
 	for {
 		// Record time before read
@@ -36,9 +32,11 @@ func copyWithRateLimit(src io.Reader, dst io.Writer, rateLimit int64, total int6
 			// Write data
 			written_bytes, writeErr := dst.Write(buf[:number_of_bytes_readed])
 			if writeErr != nil {
+				bar.finish(written)
 				return written, writeErr
 			}
 			if written_bytes != number_of_bytes_readed {
+				bar.finish(written)
 				return written, io.ErrShortWrite
 			}
 			written += int64(number_of_bytes_readed)
@@ -59,15 +57,12 @@ func copyWithRateLimit(src io.Reader, dst io.Writer, rateLimit int64, total int6
 			}
 
 			// Update progress
-			now := time.Now()
-			if now.Sub(lastUpdate) > 500*time.Millisecond || err == io.EOF {
-				showProgress(written, total, filename, time.Since(startTime), logger, background)
-				lastUpdate = now
-			}
+			bar.update(written)
 		}
 
 		if err != nil {
 			if err != io.EOF {
+				bar.finish(written)
 				return written, err
 			}
 			break
@@ -75,7 +70,6 @@ func copyWithRateLimit(src io.Reader, dst io.Writer, rateLimit int64, total int6
 	}
 
 	// Final progress update
-	showProgress(written, total, filename, time.Since(startTime), logger, background)
-	fmt.Println()
+	bar.finish(written)
 	return written, nil
 }
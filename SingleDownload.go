@@ -2,9 +2,10 @@ package main
 
 import (
 	"fmt"
+	"hash"
 	"io"
 	"log"
-	"net"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
@@ -14,91 +15,288 @@ import (
 )
 
 func DownloadOneSource(c *FlagsComponents, logger *log.Logger) error {
+	failed := 0
+	if c.concatenating() {
+		// Start the -O file empty, every URL is then appended to it
+		filename, _, err := outputPath(c.Links[0], c.OutputFile, c.PathFile)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filename, nil, 0o644); err != nil {
+			return fmt.Errorf("failed to create file: %v", err)
+		}
+	}
+	c.pending.Store(int64(len(c.Links)))
+	finished := 0
 	for _, link := range c.Links {
-		filename := c.OutputFile
-		Overide := true
-		if c.OutputFile == "" {
-			Overide = false
-			filename = GetOutputFromUrl(link)
+		if c.interrupted() {
+			break
 		}
-
-		if c.PathFile != "" {
-			filename = filepath.Join(c.PathFile, filename)
-			if strings.HasPrefix(filename, "~") {
-				homeDir, err := os.UserHomeDir()
-				if err != nil {
-					return fmt.Errorf("failed to get the home directory: %v", err)
-				}
-				filename = strings.ReplaceAll(filename, "~", homeDir)
-			}
-			if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
-				return fmt.Errorf("failed to create directory: %v", err)
+		c.pending.Add(-1)
+		if c.skipForQuota() {
+			continue
+		}
+		if c.Spider {
+			if err := c.spider(link, logger); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				failed++
 			}
+			continue
+		}
+		filename, Overide, err := outputPath(link, c.OutputFile, c.PathFile)
+		if err != nil {
+			return err
 		}
 
-		err := Download(link, c, filename, logger, Overide)
+		err = Download(link, c, filename, logger, Overide)
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
+			failed++
 			continue
 		}
+		finished++
+	}
 
+	if c.interrupted() {
+		return fmt.Errorf("%w, %d of %d downloads finished", errInterrupted, finished, len(c.Links))
 	}
 
+	if err := c.quotaError(); err != nil {
+		if failed > 0 {
+			return fmt.Errorf("%v, %d of %d downloads failed", err, failed, len(c.Links))
+		}
+		return err
+	}
+	if failed > 0 && c.Spider {
+		return fmt.Errorf("%d of %d URLs broken", failed, len(c.Links))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d downloads failed", failed, len(c.Links))
+	}
 	return nil
 }
 
+// outputPath is where link gets saved: output when given, otherwise the name
+// from the URL, inside dir when set. Overide is true for an explicit name
+func outputPath(link, output, dir string) (string, bool, error) {
+	filename := output
+	Overide := true
+	if output == "" {
+		Overide = false
+		filename = GetOutputFromUrl(link)
+	}
+
+	if dir != "" {
+		filename = filepath.Join(dir, filename)
+		if strings.HasPrefix(filename, "~") {
+			homeDir, err := os.UserHomeDir()
+			if err != nil {
+				return "", false, fmt.Errorf("failed to get the home directory: %v", err)
+			}
+			filename = strings.ReplaceAll(filename, "~", homeDir)
+		}
+		if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+			return "", false, fmt.Errorf("failed to create directory: %v", err)
+		}
+	}
+	return filename, Overide, nil
+}
+
 func GetOutputFromUrl(Link string) string {
-	sli := strings.Split(Link, "/")
+	// Name the file after the last path segment, without query or fragment
+	path := Link
+	if u, err := url.Parse(Link); err == nil {
+		path = u.Path // already percent-decoded
+	} else {
+		path, _, _ = strings.Cut(path, "?")
+		path, _, _ = strings.Cut(path, "#")
+	}
+	sli := strings.Split(path, "/")
 	filename := sli[len(sli)-1]
-	if filename == "" {
+	if filename == "" || filename == "." || filename == ".." || strings.ContainsRune(filename, 0) {
 		filename = "index.html"
 	}
 	return filename
 }
 
-func Download(Link string, c *FlagsComponents, filename string, logger *log.Logger, Overide bool) error {
+// nameFromServer names the file after the final URL of a redirect chain
+// with --trust-server-names, then after Content-Disposition if asked to
+func (c *FlagsComponents) nameFromServer(response *http.Response, filename string, say func(string)) string {
+	if c.TrustServerNames {
+		filename = filepath.Join(filepath.Dir(filename), GetOutputFromUrl(response.Request.URL.String()))
+	}
+	if c.ContentDisposition {
+		filename = serverFilename(response.Header.Get("Content-Disposition"), filename, say)
+	}
+	return filename
+}
+
+// serverFilename applies --content-disposition, it keeps the directory of
+// filename and swaps the name for the one the server suggested, if it is safe
+func serverFilename(header, filename string, say func(string)) string {
+	if header == "" {
+		return filename
+	}
+	_, params, err := mime.ParseMediaType(header)
+	if err != nil {
+		say(fmt.Sprintf("Ignoring malformed Content-Disposition: %s\n", header))
+		return filename
+	}
+	// ParseMediaType already prefers the RFC 6266 filename* form and decodes it
+	name := params["filename"]
+	if name == "" {
+		return filename
+	}
+	// A hostile server must not be able to write outside the target directory,
+	// separators are refused so ".." can only be the whole name
+	if strings.ContainsAny(name, "/\\\x00") || name == "." || name == ".." || strings.HasPrefix(name, "~") {
+		say(fmt.Sprintf("Rejecting unsafe server-suggested filename %q\n", name))
+		return filename
+	}
+	return filepath.Join(filepath.Dir(filename), name)
+}
+
+func Download(Link string, c *FlagsComponents, filename string, logger *log.Logger, Overide bool) (err error) {
+	resume := c.Continue
+	say := func(msg string) { logOrPrint(logger, c.Background, msg) }
+	defer func() {
+		if err != nil {
+			c.emit(event{Event: "error", URL: Link, Path: filename, Error: err.Error()})
+		}
+	}()
+	if resume && !c.sharedOutput() {
+		filename = partialFor(filename, Link)
+	}
+
+	// Nothing to fetch when the file we already have has the right checksum
+	sum, err := c.expectedChecksum(Link, say)
+	if err != nil {
+		return err
+	}
+	if sum != nil && !c.sharedOutput() && fileMatches(filename, sum) {
+		say(fmt.Sprintf("File '%s' already there with the expected %s checksum -- not retrieving.\n\n", filename, sum.algo))
+		c.emit(event{Event: "saved", URL: Link, Path: filename, Reason: "checksum matches"})
+		return nil
+	}
+
+	// Several URLs going into one -O file: a failed attempt is cut off
+	// again so the retry doesn't append after its leftovers
+	var start int64
+	if c.concatenating() {
+		if info, err := os.Stat(filename); err == nil {
+			start = info.Size()
+		}
+	}
+
+	err = c.retry(Link, say, func() error {
+		if c.concatenating() {
+			os.Truncate(filename, start)
+		}
+		saved, err := downloadOnce(Link, c, filename, logger, Overide, resume, sum)
+		if saved != "" && c.SaveHeaders {
+			// The saved headers would end up in the middle, start over instead
+			filename, Overide = saved, true
+		} else if saved != "" && !c.sharedOutput() {
+			// Later attempts pick up the file this one started
+			filename, resume = saved, true
+		}
+		return err
+	})
+	if err != nil && c.interrupted() {
+		// Keep the partial file and its state for -c
+		return keptPartial(Link, filename)
+	}
+	if err != nil {
+		c.dropPartial(filename, say)
+	}
+	return err
+}
+
+// downloadOnce makes a single attempt at Link and returns the path it wrote
+// to, if it got that far
+func downloadOnce(Link string, c *FlagsComponents, filename string, logger *log.Logger, Overide bool, resume bool, sum *checksum) (string, error) {
 	// Print timestamp and URL
 	logOrPrint(logger, c.Background, fmt.Sprintf("--%s--  %s\n", time.Now().Format("2006-01-02 15:04:05"), Link))
+	c.emit(event{Event: "start", URL: Link, Path: filename})
 
 	// Parse URL to get host
 	url, err := url.Parse(Link)
 	if err != nil {
-		return fmt.Errorf("failed to parse URL: %v", err)
+		return "", fmt.Errorf("failed to parse URL: %v", err)
+	}
+
+	// Split big files over several connections when asked to
+	if c.Segments > 1 {
+		saved, handled, err := downloadSegmented(Link, c, filename, logger, Overide, sum)
+		if handled || err != nil {
+			return saved, err
+		}
 	}
-	response, err := http.Get(Link)
+
+	// With -c, or on a retry, pick up where an earlier attempt stopped
+	var offset int64
+	var state *ResumeState
+	if resume {
+		offset, state = partialDownload(filename, Link)
+	}
+
+	request, err := c.newRequest("", Link)
 	if err != nil {
-		return err
+		return "", err
+	}
+	if offset > 0 {
+		setRangeHeaders(request, offset, state)
+	}
+	c.acceptEncoding(request)
+	// With -N only fetch when the server copy is newer than ours
+	var local os.FileInfo
+	if c.Timestamping && !resume {
+		if local = localCopy(filename); local != nil {
+			request.Header.Set("If-Modified-Since", local.ModTime().UTC().Format(http.TimeFormat))
+		}
+	}
+	proxy, _ := c.proxyFor(request.URL)
+	if proxy != nil {
+		logOrPrint(logger, c.Background, fmt.Sprintf("Using proxy %s\n", proxy.Redacted()))
 	}
+	response, err := c.Client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	c.emit(event{Event: "response", URL: Link, Status: response.StatusCode, Total: response.ContentLength})
+	response.Body = c.track(Link, response.ContentLength, c.countRetrieved(c.watchSpeed(response.Body)))
 
 	// Get the host name
 
-	// Look up IP address
-	ips, err := net.LookupIP(url.Host)
-	if err != nil {
-		logOrPrint(logger, c.Background, "DNS resolution failed")
-		return fmt.Errorf("failed to resolve hostname: %v", err)
-	}
-	// Print all the ips
-	var IpsTotal []string
-	for _, ip := range ips {
-		IpsTotal = append(IpsTotal, ip.String())
-	}
-	IpStr := strings.Join(IpsTotal, ", ")
-	logOrPrint(logger, c.Background, fmt.Sprintf("Resolving %s (%s)... %s\n", url.Host, url.Host, IpStr))
-	// if len(ips) > 0 {
-	// 	logOrPrint(logger, c.Background, fmt.Sprintf("Resolved to: %s", IpStr))
-	// }
+	// The response is already here, the lookup only feeds the log. Behind a
+	// proxy the proxy resolved the target, often a name we can't resolve
+	if proxy != nil {
+		logOrPrint(logger, c.Background, fmt.Sprintf("Connecting to proxy %s...", proxy.Host))
+	} else if ips, err := c.lookupIP(c.context(), url.Hostname()); err != nil || len(ips) == 0 {
+		logOrPrint(logger, c.Background, fmt.Sprintf("Connecting to %s...", url.Host))
+	} else {
+		// Print all the ips
+		var IpsTotal []string
+		for _, ip := range ips {
+			IpsTotal = append(IpsTotal, ip.String())
+		}
+		IpStr := strings.Join(IpsTotal, ", ")
+		logOrPrint(logger, c.Background, fmt.Sprintf("Resolving %s (%s)... %s\n", url.Host, url.Host, IpStr))
+		// if len(ips) > 0 {
+		// 	logOrPrint(logger, c.Background, fmt.Sprintf("Resolved to: %s", IpStr))
+		// }
 
-	// Print connecting message
-	port := "80"
-	if url.Scheme == "https" {
-		port = "443"
+		// Print connecting message
+		port := "80"
+		if url.Scheme == "https" {
+			port = "443"
+		}
+		// if !c.Background {
+		// 	fmt.Printf("Connecting to %s (%s)|%s|:%s...", url.Host, url.Host, ips[0].String(), port)
+		// } else {
+		logOrPrint(logger, c.Background, fmt.Sprintf("Connecting to %s (%s)|%s|:%s...", url.Host, url.Host, ips[0].String(), port))
+		// }
 	}
-	// if !c.Background {
-	// 	fmt.Printf("Connecting to %s (%s)|%s|:%s...", url.Host, url.Host, ips[0].String(), port)
-	// } else {
-	logOrPrint(logger, c.Background, fmt.Sprintf("Connecting to %s (%s)|%s|:%s...", url.Host, url.Host, ips[0].String(), port))
-	// }
 
 	startTime := time.Now()
 	defer response.Body.Close()
@@ -108,55 +306,163 @@ func Download(Link string, c *FlagsComponents, filename string, logger *log.Logg
 	// } else {
 	logOrPrint(logger, c.Background, " connected.\n")
 	// }
+	if response.TLS != nil {
+		logOrPrint(logger, c.Background, describeTLS(response.TLS))
+	}
 
 	// Print HTTP request status
 	logOrPrint(logger, c.Background, fmt.Sprintf("HTTP request sent, awaiting response... %s\n", response.Status))
-	if response.StatusCode != http.StatusOK {
-		return fmt.Errorf("--%s--  Error %d: %s", time.Now().Format("2006-01-02 15:04:05"), response.StatusCode, response.Status)
+	switch {
+	case local != nil && (response.StatusCode == http.StatusNotModified ||
+		response.StatusCode == http.StatusOK && upToDate(response.Header, plainLength(response), local)):
+		logOrPrint(logger, c.Background, fmt.Sprintf("Server file no newer than local file '%s' -- not retrieving.\n\n", filename))
+		c.emit(event{Event: "saved", URL: Link, Path: filename, Status: response.StatusCode, Reason: "not modified"})
+		return "", nil
+	case offset > 0 && response.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// Nothing left past the end of the local file
+		logOrPrint(logger, c.Background, "\n    The file is already fully retrieved; nothing to do.\n\n")
+		removeResumeState(filename)
+		if target := resumeTarget(filename); target != filename {
+			if err := os.Rename(target, filename); err != nil {
+				return "", fmt.Errorf("failed to move '%s' into place: %v", filename, err)
+			}
+		}
+		c.emit(event{Event: "saved", URL: Link, Path: filename, Status: response.StatusCode, Reason: "already complete"})
+		return "", nil
+	case offset > 0 && response.StatusCode == http.StatusPartialContent:
+		if start, ok := contentRangeStart(response.Header.Get("Content-Range")); !ok || start != offset {
+			return "", fmt.Errorf("server sent an unexpected range %q while resuming at byte %d", response.Header.Get("Content-Range"), offset)
+		}
+	case response.StatusCode == http.StatusOK:
+		if offset > 0 {
+			// Either ranges are not supported or If-Range did not match
+			logOrPrint(logger, c.Background, "Server ignored the range request or the file changed, restarting from the beginning.\n")
+			offset = 0
+		}
+	default:
+		return "", newHTTPStatusError(response)
+	}
+
+	// Let the server name the file unless -O did, or a partial file is being resumed
+	if !Overide && !resume {
+		filename = c.nameFromServer(response, filename, func(msg string) { logOrPrint(logger, c.Background, msg) })
 	}
 
 	// Print content length
 	fileSize := response.ContentLength
+	decoded, err := c.decodeBody(response)
+	if err != nil {
+		return "", err
+	}
 	contentType := response.Header.Get("Content-Type")
 	if contentType == "" {
 		contentType = "application/octet-stream"
 	}
 
-	if fileSize > 0 {
+	if fileSize > 0 && offset > 0 {
+		logOrPrint(logger, c.Background, fmt.Sprintf("Length: %d, %d remaining [%s]\n", offset+fileSize, fileSize, contentType))
+	} else if fileSize > 0 {
 		logOrPrint(logger, c.Background, fmt.Sprintf("Length: %d [%s]\n", fileSize, contentType))
 	} else {
 		logOrPrint(logger, c.Background, fmt.Sprintf("Length: unspecified [%s]\n", contentType))
 	}
+	// The decompressed size is only known at the end
+	total := fileSize
+	if decoded != nil {
+		logOrPrint(logger, c.Background, fmt.Sprintf("Content-Encoding: %s, decompressing on the fly\n", decoded.encoding))
+		total = -1
+	}
 
 	// Create directory if needed
 	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
-		return fmt.Errorf("failed to create directory: %v", err)
+		return "", fmt.Errorf("failed to create directory: %v", err)
 	}
 
-	// Create output file and ovrid the old if needed
-	OutputFile, err := Create_Output_file(Overide, filename)
+	// Create output file and ovrid the old if needed, with -c reuse the partial one
+	OutputFile, filename, err := c.openOutput(filename, Overide, resume, offset)
 	if err != nil {
-		return err
+		return "", err
+	}
+	if OutputFile != os.Stdout {
+		defer OutputFile.Close()
+	}
+
+	if !c.sharedOutput() {
+		// Remember which version we are writing so an interrupted run can resume it
+		err = saveResumeState(filename, &ResumeState{
+			URL:          Link,
+			ETag:         response.Header.Get("ETag"),
+			LastModified: response.Header.Get("Last-Modified"),
+		})
+		if err != nil {
+			return filename, fmt.Errorf("failed to save resume state: %v", err)
+		}
 	}
-	defer OutputFile.Close()
 
 	logOrPrint(logger, c.Background, fmt.Sprintf("Saving to: '%s'\n", filepath.Base(filename)))
+	if c.SaveHeaders {
+		if _, err := io.WriteString(OutputFile, rawHeaders(response)); err != nil {
+			return filename, fmt.Errorf("failed to write headers: %v", err)
+		}
+	}
 
 	// Download with progress - ALWAYS show progress unless in background mode
 	rate, err := parseRateLimit(c.RateLimite)
 	if err != nil {
-		return err
+		return filename, err
+	}
+	// Hash while streaming so verifying needs no second read of the file
+	var dst io.Writer = OutputFile
+	var hasher hash.Hash
+	if sum != nil {
+		hasher = sum.newHash()
+		if offset > 0 {
+			if hasher, err = hashPrefix(OutputFile.Name(), sum, offset); err != nil {
+				return filename, fmt.Errorf("failed to hash the partial file: %v", err)
+			}
+		}
+		dst = io.MultiWriter(OutputFile, hasher)
 	}
 	var downloaded int64
+	bar := c.newProgress(Link, filename, total, logger)
 	if rate > 0 {
-		downloaded, err = copyWithRateLimit(response.Body, OutputFile, rate, fileSize, filename, logger, c.Background)
+		downloaded, err = copyWithRateLimit(response.Body, dst, rate, bar)
 	} else {
-		// Show progress regardless of whether we know file size
-		downloaded, err = copyWithProgress(response.Body, OutputFile, fileSize, filepath.Base(filename), logger, c.Background)
+		downloaded, err = copyWithProgress(response.Body, dst, bar)
 	}
 
 	if err != nil {
-		return fmt.Errorf("download failed: %v", err)
+		if c.interrupted() && OutputFile != os.Stdout {
+			// Make sure what arrived is on disk for a later -c
+			OutputFile.Sync()
+		}
+		return filename, c.streamFailed(fmt.Errorf("download failed: %w", err), downloaded)
+	}
+	// A body cut short of Content-Length is a failed transfer, not a saved file
+	received := downloaded
+	if decoded != nil {
+		received = decoded.encoded
+	}
+	if fileSize > 0 && received < fileSize {
+		return filename, c.streamFailed(&truncatedError{got: received, want: fileSize}, downloaded)
+	}
+	if decoded != nil {
+		logOrPrint(logger, c.Background, decoded.String())
+	}
+	if sum != nil {
+		if got := hasher.Sum(nil); !sum.matches(got) {
+			OutputFile.Close()
+			return "", c.rejectChecksum(filename, OutputFile.Name(), sum, got)
+		}
+		logOrPrint(logger, c.Background, fmt.Sprintf("%s checksum OK\n", sum.algo))
+	}
+	if !c.sharedOutput() {
+		// Only a complete, verified file gets the final name
+		if err := commitPart(OutputFile, filename); err != nil {
+			return filename, err
+		}
+		removeResumeState(filename)
+		setServerMtime(filename, response.Header.Get("Last-Modified"))
 	}
 
 	// Calculate download speed and time
@@ -168,17 +474,15 @@ func Download(Link string, c *FlagsComponents, filename string, logger *log.Logg
 		formatSpeed(speed),
 		filepath.Base(filename),
 		downloaded))
+	c.emit(event{Event: "saved", URL: Link, Path: filename, Status: response.StatusCode, Bytes: downloaded, Total: fileSize, Elapsed: duration.Seconds()})
 
-	return nil
+	return filename, nil
 }
 
-func copyWithProgress(src io.Reader, dst io.Writer, total int64, filename string, logger *log.Logger, background bool) (int64, error) {
+func copyWithProgress(src io.Reader, dst io.Writer, bar *progress) (int64, error) {
 	var written int64
 	buf := make([]byte, 32*1024)
 
-	startTime := time.Now()
-	lastUpdate := time.Now()
-
 	for {
 		number_of_bytes_readed, err := src.Read(buf)
 		if number_of_bytes_readed > 0 {
@@ -187,21 +491,18 @@ func copyWithProgress(src io.Reader, dst io.Writer, total int64, filename string
 				written += int64(number_of_byte_writed)
 			}
 			if err2 != nil {
+				bar.finish(written)
 				return written, err2
 			}
 			if number_of_bytes_readed != number_of_byte_writed {
+				bar.finish(written)
 				return written, io.ErrShortWrite
 			}
-
-			// Update progress more frequently - every 10ms or when finished
-			now := time.Now()
-			if now.Sub(lastUpdate) > 500*time.Millisecond || err == io.EOF {
-				showProgress(written, total, filename, time.Since(startTime), logger, background)
-				lastUpdate = now
-			}
+			bar.update(written)
 		}
 		if err != nil {
 			if err != io.EOF {
+				bar.finish(written)
 				return written, err
 			}
 
@@ -209,95 +510,6 @@ func copyWithProgress(src io.Reader, dst io.Writer, total int64, filename string
 		}
 	}
 
-	// Final progress update
-	showProgress(written, total, filename, time.Since(startTime), logger, background)
-	fmt.Println()
+	bar.finish(written)
 	return written, nil
 }
-
-func showProgress(downloaded, total int64, filename string, duration time.Duration, logger *log.Logger, background bool) {
-	// If in background mode, log progress periodically instead of showing progress bar
-	// if background {
-	// 	// Log progress every MB or when complete
-	// 	if downloaded%(1024*1024) == 0 || (total > 0 && downloaded >= total) {
-	// 		speed := float64(downloaded) / duration.Seconds() / (1024 * 1024)
-	// 		logOrPrint(logger, background, fmt.Sprintf("Downloaded: %.2fMB, Speed: %.2fMB/s",
-	// 			float64(downloaded)/(1024*1024), speed))
-	// 	}
-	// }
-
-	speed := float64(downloaded) / duration.Seconds() / (1024 * 1024)
-
-	// Create progress bar similar to wget
-	barWidth := 80 // Reduced width to fit better
-	var progressBar string
-
-	if total > 0 {
-		// Known file size - show normal progress bar
-		percentage := float64(downloaded) / float64(total) * 100
-		filled := int(percentage / 100 * float64(barWidth))
-
-		progressBar = strings.Repeat("=", filled)
-		if filled < barWidth {
-			progressBar += ">"
-			progressBar += strings.Repeat(" ", barWidth-filled-1)
-		}
-	} else {
-		// Unknown file size - show indeterminate progress (like wget's <=>)
-		// Create a moving indicator
-		pos := int(time.Now().UnixMilli()/100) % (barWidth - 6)
-		progressBar = strings.Repeat(" ", pos) + "  <=>  " + strings.Repeat(" ", barWidth-pos-6)
-	}
-
-	// downloaded file size
-	var filesize string
-	if downloaded/(1024*1024) > 1 {
-		filesize = fmt.Sprintf("%.2fM", float64(downloaded)/(1024*1024))
-	} else {
-		filesize = fmt.Sprintf("%.2fK", float64(downloaded)/(1024))
-	}
-	// fmt.Println("bbbbbbbbbb", background)
-	// if !background {
-	// 	fmt.Printf("\r%-20s [%s] %s %s", filename, progressBar, filesize, formatSpeed(speed))
-	// 	// fmt.Printf("\r %s %s %s %s", filename, progressBar, filesize, formatSpeed(speed))
-	// } else {
-	// fmt.Println("hanni")
-	// fmt.Println("")
-	if background {
-		remaining := total - downloaded
-		// remaining_Sec := time.Duration(float64(remaining)/speed) * time.Second
-		// fmt.Println(time.Duration(float64(remaining)/speed).Seconds() * 10)
-		remainingStr := formatETA(time.Duration(float64(remaining)/speed) * 10)
-		logOrPrint(logger, background, fmt.Sprintf("%dK %s %.0f%% %s %s", downloaded, strings.ReplaceAll(progressBar, "=", "."), float64(downloaded*100)/float64(total), formatSpeed(speed), remainingStr))
-	} else {
-		logOrPrint(logger, background, fmt.Sprintf("\r%s %.0f%% [%s] %s %s", filename, float64(downloaded*100)/float64(total), progressBar, filesize, formatSpeed(speed)))
-	}
-	// }
-
-	// Add timing info
-	if total > 0 && downloaded >= total {
-		// Complete - show "in Xs"
-		// if !background {
-		// 	fmt.Printf(" in %.1fs", duration.Seconds())
-		// } else {
-		// if {
-			logOrPrint(logger, background, fmt.Sprintf(" in %.2fs", duration.Seconds()))
-		// }
-		// }
-	} 
-	// else if total > 0 && downloaded < total && speed > 0 {
-	// 	// Show ETA
-	// 	// remaining := total - downloaded
-	// 	// eta := time.Duration(float64(remaining)/speed) * 1000
-	// 	// if !background {
-	// 	// 	fmt.Printf(" eta %.1f", eta.Seconds())
-	// 	// } else {
-	// 	// logOrPrint(logger, background, fmt.Sprintf(" in %.1fs", duration.Seconds()))
-	// 	// if background {
-	// 	// 	logOrPrint(logger, background, fmt.Sprintf(" %.2f", eta.Seconds()))
-	// 	// }
-	// 	// }
-	// }
-
-	os.Stdout.Sync()
-}
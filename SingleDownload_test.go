@@ -0,0 +1,34 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestServerFilename(t *testing.T) {
+	fallback := filepath.Join("dir", "download")
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", fallback},
+		{"attachment", fallback},
+		{`attachment; filename="report.pdf"`, filepath.Join("dir", "report.pdf")},
+		{`attachment; filename="foo..tar.gz"`, filepath.Join("dir", "foo..tar.gz")},
+		{`attachment; filename="..hidden"`, filepath.Join("dir", "..hidden")},
+		{`attachment; filename*=UTF-8''na%C3%AFve%20file.txt`, filepath.Join("dir", "naïve file.txt")},
+		{`attachment; filename="plain.txt"; filename*=UTF-8''better.txt`, filepath.Join("dir", "better.txt")},
+		{`attachment; filename=".."`, fallback},
+		{`attachment; filename="."`, fallback},
+		{`attachment; filename="../etc/passwd"`, fallback},
+		{`attachment; filename="a/b.txt"`, fallback},
+		{`attachment; filename="a\\b.txt"`, fallback},
+		{`attachment; filename="~root"`, fallback},
+		{`attachment; filename="bad`, fallback},
+	}
+	for _, tt := range tests {
+		if got := serverFilename(tt.header, fallback, func(string) {}); got != tt.want {
+			t.Errorf("serverFilename(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+type credentials struct {
+	user     string
+	password string
+}
+
+// credentialsFor picks the login for u. Credentials given on the command
+// line, and the netrc default entry, only go to the scheme, host and port of
+// the URLs we were asked for, so a redirect elsewhere never sees them. A
+// netrc machine entry is for that host whatever the port
+func (c *FlagsComponents) credentialsFor(u *url.URL) *credentials {
+	c.authOnce.Do(func() {
+		c.netrc = loadNetrc()
+		c.authOrigins = make(map[string]bool)
+		for _, link := range c.Links {
+			if target, err := url.Parse(link); err == nil {
+				c.authOrigins[origin(target)] = true
+			}
+		}
+	})
+	named := c.authOrigins[origin(u)]
+
+	user, password := c.User, c.Password
+	if c.HTTPUser != "" {
+		user = c.HTTPUser
+	}
+	if c.HTTPPassword != "" {
+		password = c.HTTPPassword
+	}
+	if user != "" && named {
+		return &credentials{user: user, password: password}
+	}
+	if creds, ok := c.netrc[strings.ToLower(u.Hostname())]; ok {
+		return creds
+	}
+	if named {
+		return c.netrc["default"]
+	}
+	return nil
+}
+
+// origin is scheme://host:port of u, with the default port filled in
+func origin(u *url.URL) string {
+	scheme := strings.ToLower(u.Scheme)
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if scheme == "https" {
+			port = "443"
+		}
+	}
+	return scheme + "://" + net.JoinHostPort(strings.ToLower(u.Hostname()), port)
+}
+
+// askPassword reads the password from the terminal without echoing it
+func (c *FlagsComponents) askPassword() error {
+	user := c.User
+	if c.HTTPUser != "" {
+		user = c.HTTPUser
+	}
+	if user == "" {
+		return fmt.Errorf("--ask-password requires --user or --http-user")
+	}
+	fmt.Fprintf(os.Stderr, "Password for user '%s': ", user)
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return fmt.Errorf("failed to read password: %v", err)
+	}
+	c.Password, c.HTTPPassword = string(password), ""
+	return nil
+}
+
+// loadNetrc reads $NETRC or ~/.netrc, keyed by machine name
+func loadNetrc() map[string]*credentials {
+	path := os.Getenv("NETRC")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil
+		}
+		path = filepath.Join(home, ".netrc")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	entries := make(map[string]*credentials)
+	var current *credentials
+	scanner := bufio.NewScanner(file)
+	inMacro := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		// A macro definition runs until the next empty line
+		if inMacro {
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			switch fields[i] {
+			case "machine":
+				if i+1 < len(fields) {
+					i++
+					current = &credentials{}
+					if _, exists := entries[strings.ToLower(fields[i])]; !exists {
+						entries[strings.ToLower(fields[i])] = current
+					}
+				}
+			case "default":
+				current = &credentials{}
+				if _, exists := entries["default"]; !exists {
+					entries["default"] = current
+				}
+			case "login":
+				if current != nil && i+1 < len(fields) {
+					i++
+					current.user = fields[i]
+				}
+			case "password":
+				if current != nil && i+1 < len(fields) {
+					i++
+					current.password = fields[i]
+				}
+			case "account":
+				i++
+			case "macdef":
+				inMacro = true
+				i = len(fields)
+			}
+		}
+	}
+	return entries
+}
+
+// challenge is one scheme offered in a WWW-Authenticate header
+type challenge struct {
+	scheme string
+	params map[string]string
+	nc     int
+}
+
+// parseChallenges splits WWW-Authenticate values into their challenges,
+// a header may carry several of them separated by commas
+func parseChallenges(values []string) []*challenge {
+	var challenges []*challenge
+	for _, value := range values {
+		var current *challenge
+		s := value
+		for {
+			s = strings.TrimLeft(s, " \t,")
+			if s == "" {
+				break
+			}
+			token := s
+			if i := strings.IndexAny(s, " \t,="); i >= 0 {
+				token = s[:i]
+			}
+			rest := strings.TrimLeft(s[len(token):], " \t")
+			if !strings.HasPrefix(rest, "=") {
+				// A bare token starts a new challenge
+				current = &challenge{scheme: strings.ToLower(token), params: map[string]string{}}
+				challenges = append(challenges, current)
+				s = rest
+				continue
+			}
+			// key=value or key="quoted value"
+			rest = strings.TrimLeft(rest[1:], " \t")
+			var val string
+			if strings.HasPrefix(rest, `"`) {
+				var b strings.Builder
+				i := 1
+				for ; i < len(rest) && rest[i] != '"'; i++ {
+					if rest[i] == '\\' && i+1 < len(rest) {
+						i++
+					}
+					b.WriteByte(rest[i])
+				}
+				val = b.String()
+				if i < len(rest) {
+					i++
+				}
+				rest = rest[i:]
+			} else {
+				end := strings.IndexAny(rest, ", \t")
+				if end < 0 {
+					end = len(rest)
+				}
+				val, rest = rest[:end], rest[end:]
+			}
+			if current != nil {
+				current.params[strings.ToLower(token)] = val
+			}
+			s = rest
+		}
+	}
+	return challenges
+}
+
+// pickChallenge prefers Digest over Basic
+func pickChallenge(challenges []*challenge) *challenge {
+	var basic *challenge
+	for _, ch := range challenges {
+		switch ch.scheme {
+		case "digest":
+			if digestHash(ch.params["algorithm"]) != nil {
+				return ch
+			}
+		case "basic":
+			basic = ch
+		}
+	}
+	return basic
+}
+
+// digestHash maps an RFC 7616 algorithm name to its hash, nil if unsupported
+func digestHash(algorithm string) func() hash.Hash {
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "", "MD5":
+		return md5.New
+	case "SHA-256":
+		return sha256.New
+	case "SHA-512-256":
+		return sha512.New512_256
+	}
+	return nil
+}
+
+// authorization builds the Authorization header answering ch for req
+func (ch *challenge) authorization(req *http.Request, creds *credentials) string {
+	if ch.scheme == "basic" {
+		r := &http.Request{Header: http.Header{}}
+		r.SetBasicAuth(creds.user, creds.password)
+		return r.Header.Get("Authorization")
+	}
+
+	newHash := digestHash(ch.params["algorithm"])
+	h := func(s string) string {
+		sum := newHash()
+		sum.Write([]byte(s))
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+
+	realm, nonce := ch.params["realm"], ch.params["nonce"]
+	uri := req.URL.RequestURI()
+	ch.nc++
+	nc := fmt.Sprintf("%08x", ch.nc)
+	cnonceBytes := make([]byte, 16)
+	rand.Read(cnonceBytes)
+	cnonce := hex.EncodeToString(cnonceBytes)
+
+	ha1 := h(creds.user + ":" + realm + ":" + creds.password)
+	if strings.HasSuffix(strings.ToUpper(ch.params["algorithm"]), "-SESS") {
+		ha1 = h(ha1 + ":" + nonce + ":" + cnonce)
+	}
+	ha2 := h(req.Method + ":" + uri)
+
+	qop := ""
+	for _, q := range strings.Split(ch.params["qop"], ",") {
+		if strings.TrimSpace(q) == "auth" {
+			qop = "auth"
+		}
+	}
+	var response string
+	if qop != "" {
+		response = h(strings.Join([]string{ha1, nonce, nc, cnonce, qop, ha2}, ":"))
+	} else {
+		response = h(ha1 + ":" + nonce + ":" + ha2)
+	}
+
+	username := creds.user
+	if strings.EqualFold(ch.params["userhash"], "true") {
+		username = h(creds.user + ":" + realm)
+	}
+
+	parts := []string{
+		fmt.Sprintf(`username="%s"`, username),
+		fmt.Sprintf(`realm="%s"`, realm),
+		fmt.Sprintf(`nonce="%s"`, nonce),
+		fmt.Sprintf(`uri="%s"`, uri),
+		fmt.Sprintf(`response="%s"`, response),
+	}
+	if alg := ch.params["algorithm"]; alg != "" {
+		parts = append(parts, "algorithm="+alg)
+	}
+	if qop != "" {
+		parts = append(parts, "qop="+qop, "nc="+nc, fmt.Sprintf(`cnonce="%s"`, cnonce))
+	}
+	if opaque, ok := ch.params["opaque"]; ok {
+		parts = append(parts, fmt.Sprintf(`opaque="%s"`, opaque))
+	}
+	if _, ok := ch.params["userhash"]; ok {
+		parts = append(parts, "userhash="+strings.ToLower(ch.params["userhash"]))
+	}
+	return "Digest " + strings.Join(parts, ", ")
+}
+
+// authTransport answers Basic and Digest challenges. It sits under the
+// http.Client so every hop of a redirect is checked against its own origin
+type authTransport struct {
+	base http.RoundTripper
+	c    *FlagsComponents
+
+	mu         sync.Mutex
+	challenges map[string]*challenge
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := origin(req.URL)
+	creds := t.c.credentialsFor(req.URL)
+	if creds == nil || req.Header.Get("Authorization") != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	// Answer up front when the host already challenged us, or with Basic
+	// when --auth-no-challenge says not to wait for a 401
+	t.mu.Lock()
+	known := t.challenges[host]
+	var header string
+	if known != nil {
+		header = known.authorization(req, creds)
+	} else if t.c.AuthNoChallenge {
+		header = (&challenge{scheme: "basic"}).authorization(req, creds)
+	}
+	t.mu.Unlock()
+
+	first := req
+	if header != "" {
+		first = req.Clone(req.Context())
+		first.Header.Set("Authorization", header)
+	}
+	resp, err := t.base.RoundTrip(first)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	ch := pickChallenge(parseChallenges(resp.Header.Values("WWW-Authenticate")))
+	if ch == nil {
+		return resp, nil
+	}
+	// Same credentials were already refused, only a stale Digest nonce is worth a second go
+	if header != "" && !strings.EqualFold(ch.params["stale"], "true") {
+		return resp, nil
+	}
+	// The body is gone unless it can be replayed
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+	resp.Body.Close()
+
+	t.mu.Lock()
+	if t.challenges == nil {
+		t.challenges = make(map[string]*challenge)
+	}
+	t.challenges[host] = ch
+	retry.Header.Set("Authorization", ch.authorization(retry, creds))
+	t.mu.Unlock()
+
+	return t.base.RoundTrip(retry)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseChallenges(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   []*challenge
+	}{
+		{
+			[]string{`Basic realm="files"`},
+			[]*challenge{{scheme: "basic", params: map[string]string{"realm": "files"}}},
+		},
+		{
+			[]string{`Digest realm="a, b", nonce=abc, qop="auth,auth-int", algorithm=SHA-256, Basic realm=x`},
+			[]*challenge{
+				{scheme: "digest", params: map[string]string{"realm": "a, b", "nonce": "abc", "qop": "auth,auth-int", "algorithm": "SHA-256"}},
+				{scheme: "basic", params: map[string]string{"realm": "x"}},
+			},
+		},
+		{
+			[]string{`Bearer`, `Digest Realm="say \"hi\""`},
+			[]*challenge{
+				{scheme: "bearer", params: map[string]string{}},
+				{scheme: "digest", params: map[string]string{"realm": `say "hi"`}},
+			},
+		},
+		{[]string{""}, nil},
+	}
+	for _, tt := range tests {
+		if got := parseChallenges(tt.values); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseChallenges(%q) = %+v, want %+v", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestPickChallenge(t *testing.T) {
+	challenges := parseChallenges([]string{`Basic realm=x, Digest realm=y, nonce=n, algorithm=SHA-512`, `Digest realm=z, nonce=n`})
+	if got := pickChallenge(challenges); got.scheme != "digest" || got.params["realm"] != "z" {
+		t.Errorf("pickChallenge picked %+v, want the supported Digest", got)
+	}
+	if got := pickChallenge(parseChallenges([]string{`Basic realm=x`})); got == nil || got.scheme != "basic" {
+		t.Errorf("pickChallenge picked %+v, want Basic", got)
+	}
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// The example exchange of RFC 2617 section 3.5
+func TestDigestAuthorization(t *testing.T) {
+	creds := &credentials{user: "Mufasa", password: "Circle Of Life"}
+	req, _ := http.NewRequest("GET", "http://www.nowhere.org/dir/index.html", nil)
+
+	// RFC 2069 style, without qop, has no client nonce
+	ch := parseChallenges([]string{`Digest realm="testrealm@host.com", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", opaque="5ccc069c403ebaf9f0171e9517f40e41"`})[0]
+	want := `Digest username="Mufasa", realm="testrealm@host.com", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", uri="/dir/index.html", ` +
+		`response="670fd8c2df070c60b045671b8b24ff02", opaque="5ccc069c403ebaf9f0171e9517f40e41"`
+	if got := ch.authorization(req, creds); got != want {
+		t.Errorf("authorization without qop\n got %s\nwant %s", got, want)
+	}
+
+	ch = parseChallenges([]string{`Digest realm="testrealm@host.com", qop="auth,auth-int", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093"`})[0]
+	for _, nc := range []string{"00000001", "00000002"} {
+		answer := parseChallenges([]string{ch.authorization(req, creds)})[0]
+		if answer.params["nc"] != nc || answer.params["qop"] != "auth" || answer.params["cnonce"] == "" {
+			t.Fatalf("authorization params = %v, want qop=auth nc=%s and a cnonce", answer.params, nc)
+		}
+		ha1 := md5Hex("Mufasa:testrealm@host.com:Circle Of Life")
+		ha2 := md5Hex("GET:/dir/index.html")
+		want := md5Hex(strings.Join([]string{ha1, "dcd98b7102dd2f0e8b11d0f600bfb0c093", nc, answer.params["cnonce"], "auth", ha2}, ":"))
+		if answer.params["response"] != want {
+			t.Errorf("response = %s, want %s", answer.params["response"], want)
+		}
+	}
+
+	basic := (&challenge{scheme: "basic"}).authorization(req, creds)
+	if basic != "Basic TXVmYXNhOkNpcmNsZSBPZiBMaWZl" {
+		t.Errorf("basic authorization = %s", basic)
+	}
+}
+
+func TestCredentialsScope(t *testing.T) {
+	netrc := filepath.Join(t.TempDir(), "netrc")
+	data := "machine Files.Example.com login alice password secret\ndefault login anon password guest\n"
+	if err := os.WriteFile(netrc, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NETRC", netrc)
+
+	c := &FlagsComponents{
+		User:     "bob",
+		Password: "pw",
+		Links:    []string{"https://example.com/a", "http://other.example:8080/b"},
+	}
+	tests := []struct {
+		link string
+		user string
+	}{
+		// Command line credentials, for the origins named only
+		{"https://example.com/c", "bob"},
+		{"https://EXAMPLE.com:443/c", "bob"},
+		{"http://other.example:8080/x", "bob"},
+		{"http://example.com/c", ""},
+		{"https://example.com:8443/c", ""},
+		{"http://other.example/x", ""},
+		// netrc machines go to their host on any port
+		{"https://files.example.com/f", "alice"},
+		{"http://files.example.com:8000/f", "alice"},
+		// The netrc default never follows a redirect to another host
+		{"https://elsewhere.example/", ""},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.link)
+		got := ""
+		if creds := c.credentialsFor(u); creds != nil {
+			got = creds.user
+		}
+		if got != tt.user {
+			t.Errorf("credentialsFor(%s) = %q, want %q", tt.link, got, tt.user)
+		}
+	}
+
+	// Without command line credentials the named hosts get the default
+	c = &FlagsComponents{Links: []string{"https://example.com/a"}}
+	for link, want := range map[string]string{"https://example.com/b": "anon", "https://elsewhere.example/": ""} {
+		u, _ := url.Parse(link)
+		got := ""
+		if creds := c.credentialsFor(u); creds != nil {
+			got = creds.user
+		}
+		if got != want {
+			t.Errorf("credentialsFor(%s) without --user = %q, want %q", link, got, want)
+		}
+	}
+}
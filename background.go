@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// backgroundLogEnv tells the re-executed process which log to write to, it
+// is also how that process knows it is already the background one
+const backgroundLogEnv = "GO_WGET_BACKGROUND_LOG"
+
+// detach runs the same command again in a new session, with stdin from
+// /dev/null and its output going to a log of its own, so -B survives the
+// terminal it was started from
+func (c *FlagsComponents) detach() error {
+	logFile, err := createLog("wget-log")
+	if err != nil {
+		return fmt.Errorf("failed to create log file: %v", err)
+	}
+	defer logFile.Close()
+	logPath, err := filepath.Abs(logFile.Name())
+	if err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to start in background: %v", err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to start in background: %v", err)
+	}
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		return fmt.Errorf("failed to start in background: %v", err)
+	}
+	defer devNull.Close()
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Dir = wd
+	cmd.Env = append(os.Environ(), backgroundLogEnv+"="+logPath)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = devNull, logFile, logFile
+	cmd.SysProcAttr = detachedProcess()
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start in background: %v", err)
+	}
+	// Written here too so it is there as soon as we return to the shell
+	if c.PIDFile != "" {
+		if err := writePIDFile(c.PIDFile, cmd.Process.Pid); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Continuing in background, pid %d.\nOutput will be written to '%s'.\n", cmd.Process.Pid, logFile.Name())
+	return cmd.Process.Release()
+}
+
+// createLog creates the first of name, name.1, name.2... that doesn't exist
+// yet. O_EXCL keeps background jobs started together from sharing a log
+func createLog(name string) (*os.File, error) {
+	for i := 0; ; i++ {
+		path := name
+		if i > 0 {
+			path = fmt.Sprintf("%s.%d", name, i)
+		}
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_EXCL, 0o644)
+		if !os.IsExist(err) {
+			return file, err
+		}
+	}
+}
+
+// writePIDFile records pid for --pid-file
+func writePIDFile(path string, pid int) error {
+	if err := os.WriteFile(path, []byte(strconv.Itoa(pid)+"\n"), 0o644); err != nil {
+		return fmt.Errorf("failed to write pid file: %v", err)
+	}
+	return nil
+}
+
+// removePIDFile cleans up on exit, unless another run has taken the file over
+func removePIDFile(path string) {
+	data, err := os.ReadFile(path)
+	if err == nil && strings.TrimSpace(string(data)) == strconv.Itoa(os.Getpid()) {
+		os.Remove(path)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "syscall"
+
+// detachedProcess has nothing to add where there are no sessions
+func detachedProcess() *syscall.SysProcAttr {
+	return nil
+}
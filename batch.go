@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// batchEntry is one URL of an -i file and the options indented below it
+type batchEntry struct {
+	link     string
+	line     int
+	output   string
+	dir      string
+	checksum *checksum
+	headers  http.Header
+}
+
+// readBatch reads an -i file, "-" means stdin. The format follows aria2: one
+// URL per line, with its own options on the indented lines under it
+//
+//	https://example.com/debian.iso
+//	  out=debian-12.iso
+//	  dir=isos
+//	  checksum=sha-256=<hex>
+//	  header=Authorization: Bearer <token>
+func readBatch(path string) ([]*batchEntry, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open input file: %v", err)
+		}
+		defer file.Close()
+		r = file
+	}
+
+	var entries []*batchEntry
+	var current *batchEntry
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if line[0] != ' ' && line[0] != '\t' {
+			// aria2 lists mirrors of the same file separated by tabs, we take the first
+			link, _, _ := strings.Cut(trimmed, "\t")
+			if !strings.HasPrefix(link, "http") {
+				link = "http://" + link
+			}
+			current = &batchEntry{link: link, line: n}
+			entries = append(entries, current)
+			continue
+		}
+
+		if current == nil {
+			return nil, fmt.Errorf("%s:%d: option before any URL", path, n)
+		}
+		name, value, ok := strings.Cut(trimmed, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected name=value, got %q", path, n, trimmed)
+		}
+		var err error
+		switch strings.TrimSpace(name) {
+		case "out":
+			current.output = value
+		case "dir":
+			current.dir = value
+		case "checksum":
+			current.checksum, err = parseBatchChecksum(value)
+		case "header":
+			if current.headers == nil {
+				current.headers = make(http.Header)
+			}
+			err = parseHeader(value, current.headers)
+		default:
+			err = fmt.Errorf("unknown option %q, expected out, dir, checksum or header", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read input file: %v", err)
+	}
+	return entries, nil
+}
+
+// parseBatchChecksum takes aria2's sha-256=<hex> as well as our sha256:<hex>
+func parseBatchChecksum(value string) (*checksum, error) {
+	if algo, digest, ok := strings.Cut(value, "="); ok && !strings.Contains(algo, ":") {
+		value = strings.ReplaceAll(algo, "-", "") + ":" + digest
+	}
+	return parseChecksum(value)
+}
+
+// setBatch makes the entries the URLs of this run. Per-line options are
+// looked up by URL, so one URL can't be listed twice with different ones
+func (c *FlagsComponents) setBatch(entries []*batchEntry) error {
+	c.Links = nil
+	c.batchOptions = make(map[string]*batchEntry)
+	for _, entry := range entries {
+		if previous, ok := c.batchOptions[entry.link]; ok && (previous.checksum != nil || previous.headers != nil ||
+			entry.checksum != nil || entry.headers != nil) {
+			return fmt.Errorf("%s:%d: %s is already listed on line %d, options can't differ between copies of a URL",
+				c.InputFile, entry.line, entry.link, previous.line)
+		}
+		c.batchOptions[entry.link] = entry
+		c.Links = append(c.Links, entry.link)
+	}
+	return nil
+}
+
+// downloadBatch runs the entries on --jobs workers and sums up how it went
+func (c *FlagsComponents) downloadBatch(entries []*batchEntry, logger *log.Logger) error {
+	jobs := max(c.Jobs, 1)
+	queue := make(chan *batchEntry)
+	var mu sync.Mutex
+	var failed []*batchEntry
+	var notStarted int
+	var wg sync.WaitGroup
+	c.pending.Store(int64(len(entries)))
+	stopDashboard := c.startDashboard()
+	for range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range queue {
+				c.pending.Add(-1)
+				if c.interrupted() {
+					mu.Lock()
+					notStarted++
+					mu.Unlock()
+					continue
+				}
+				if c.skipForQuota() {
+					continue
+				}
+				if err := c.downloadEntry(entry, logger); err != nil {
+					fmt.Fprintln(Stderr, err)
+					mu.Lock()
+					failed = append(failed, entry)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, entry := range entries {
+		queue <- entry
+	}
+	close(queue)
+	wg.Wait()
+	stopDashboard()
+
+	done, broken := "downloaded", "failed"
+	if c.Spider {
+		done, broken = "reachable", "broken"
+	}
+	skipped := int(c.quotaSkipped.Load())
+	summary := fmt.Sprintf("\nFINISHED: %d of %d URLs %s, %d %s\n", len(entries)-len(failed)-skipped-notStarted, len(entries), done, len(failed), broken)
+	for _, entry := range failed {
+		summary += fmt.Sprintf("  %s: %s (line %d)\n", broken, entry.link, entry.line)
+	}
+	if err := c.quotaError(); err != nil {
+		summary += fmt.Sprintf("  %v\n", err)
+	}
+	if notStarted > 0 {
+		summary += fmt.Sprintf("  %v, %d URLs not started\n", errInterrupted, notStarted)
+	}
+	logOrPrint(logger, c.Background, summary)
+	if err := c.quotaError(); err != nil {
+		return err
+	}
+	if c.interrupted() {
+		return fmt.Errorf("%w, %d URLs not started", errInterrupted, notStarted)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d URLs %s", len(failed), len(entries), broken)
+	}
+	return nil
+}
+
+func (c *FlagsComponents) downloadEntry(entry *batchEntry, logger *log.Logger) error {
+	if c.Spider {
+		return c.spider(entry.link, logger)
+	}
+	dir := c.PathFile
+	if entry.dir != "" {
+		dir = entry.dir
+	}
+	filename, Overide, err := outputPath(entry.link, entry.output, dir)
+	if err != nil {
+		return err
+	}
+	return Download(entry.link, c, filename, logger, Overide)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadBatch(t *testing.T) {
+	input := strings.Join([]string{
+		"# downloads for today",
+		"https://example.com/debian.iso\thttps://mirror.example.com/debian.iso",
+		"  out=debian-12.iso",
+		"\tdir=isos",
+		"  checksum=sha-256=" + sumA,
+		"  header=Authorization: Bearer token",
+		"",
+		"example.com/plain.txt",
+		"http://example.com/other.txt",
+		"  checksum=sha256:" + sumB,
+	}, "\n")
+	path := filepath.Join(t.TempDir(), "urls.txt")
+	if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := readBatch(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+
+	iso := entries[0]
+	if iso.link != "https://example.com/debian.iso" || iso.line != 2 || iso.output != "debian-12.iso" || iso.dir != "isos" {
+		t.Errorf("first entry = %+v", iso)
+	}
+	if iso.checksum == nil || iso.checksum.String() != "sha256:"+sumA {
+		t.Errorf("first entry checksum = %v, want sha256:%s", iso.checksum, sumA)
+	}
+	if got := iso.headers.Get("Authorization"); got != "Bearer token" {
+		t.Errorf("first entry Authorization = %q", got)
+	}
+	if entries[1].link != "http://example.com/plain.txt" || entries[1].checksum != nil || entries[1].headers != nil {
+		t.Errorf("second entry = %+v", entries[1])
+	}
+	if entries[2].checksum == nil || entries[2].checksum.String() != "sha256:"+sumB {
+		t.Errorf("third entry checksum = %v, want sha256:%s", entries[2].checksum, sumB)
+	}
+}
+
+func TestReadBatchErrors(t *testing.T) {
+	tests := []struct {
+		input, want string
+	}{
+		{"  out=x\nhttp://example.com/", ":1: option before any URL"},
+		{"http://example.com/\n  out", ":2: expected name=value"},
+		{"http://example.com/\n  speed=1M", `:2: unknown option "speed"`},
+		{"http://example.com/\n  checksum=sha-256=zz", ":2: invalid sha256 checksum"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "urls.txt")
+		os.WriteFile(path, []byte(tt.input), 0o644)
+		if _, err := readBatch(path); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("readBatch(%q) error %v, want %q", tt.input, err, tt.want)
+		}
+	}
+	if _, err := readBatch(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("a missing input file didn't fail")
+	}
+}
+
+func TestSetBatchRejectsConflictingCopies(t *testing.T) {
+	plain := func(line int) *batchEntry { return &batchEntry{link: "http://example.com/a", line: line} }
+	c := &FlagsComponents{}
+	if err := c.setBatch([]*batchEntry{plain(1), plain(2)}); err != nil || len(c.Links) != 2 {
+		t.Errorf("plain duplicates: %v, %d links", err, len(c.Links))
+	}
+	sum, _ := parseChecksum("sha256:" + sumA)
+	withSum := plain(3)
+	withSum.checksum = sum
+	if err := c.setBatch([]*batchEntry{plain(1), withSum}); err == nil {
+		t.Error("a duplicate URL with its own checksum was accepted")
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// checksum is an expected digest, from --checksum or a checksum file
+type checksum struct {
+	algo string
+	sum  []byte
+}
+
+var checksumAlgos = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// parseChecksum reads --checksum=<algo>:<hex>
+func parseChecksum(value string) (*checksum, error) {
+	algo, digest, ok := strings.Cut(value, ":")
+	algo = strings.ToLower(strings.TrimSpace(algo))
+	if !ok || checksumAlgos[algo] == nil {
+		return nil, fmt.Errorf("invalid checksum %q, expected sha256:<hex>, sha1:, sha512: or md5:", value)
+	}
+	sum, err := hex.DecodeString(strings.TrimSpace(digest))
+	if err != nil || len(sum) != checksumAlgos[algo]().Size() {
+		return nil, fmt.Errorf("invalid %s checksum: %s", algo, digest)
+	}
+	return &checksum{algo: algo, sum: sum}, nil
+}
+
+func (s *checksum) newHash() hash.Hash {
+	return checksumAlgos[s.algo]()
+}
+
+func (s *checksum) matches(sum []byte) bool {
+	return bytes.Equal(s.sum, sum)
+}
+
+func (s *checksum) String() string {
+	return s.algo + ":" + hex.EncodeToString(s.sum)
+}
+
+// fileMatches hashes an existing file, false if it is missing
+func fileMatches(filename string, sum *checksum) bool {
+	got, err := hashFile(filename, sum, -1)
+	return err == nil && sum.matches(got)
+}
+
+// hashFile hashes the first limit bytes of filename, all of it when limit is negative
+func hashFile(filename string, sum *checksum, limit int64) ([]byte, error) {
+	h, err := hashPrefix(filename, sum, limit)
+	if err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// hashPrefix feeds the first limit bytes of filename into a new hash, so a
+// resumed download can keep hashing where the partial file ends
+func hashPrefix(filename string, sum *checksum, limit int64) (hash.Hash, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	h := sum.newHash()
+	var src io.Reader = file
+	if limit >= 0 {
+		src = io.LimitReader(file, limit)
+	}
+	if _, err := io.Copy(h, src); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// rejectChecksum removes the file written for filename after it failed
+// verification, or keeps it aside with a .bad suffix when --keep-bad-checksum
+func (c *FlagsComponents) rejectChecksum(filename, written string, want *checksum, got []byte) error {
+	err := fmt.Errorf("checksum mismatch for '%s': expected %s, got %s:%x", filename, want, want.algo, got)
+	if c.sharedOutput() {
+		// Other downloads share the output, there is nothing of ours to remove
+		return err
+	}
+	// The download never left its .part file, unless -c resumed in place
+	removeResumeState(filename)
+	if c.KeepBadChecksum {
+		if renameErr := os.Rename(written, filename+".bad"); renameErr != nil {
+			return fmt.Errorf("%v (and failed to rename it: %v)", err, renameErr)
+		}
+		return fmt.Errorf("%v, kept as '%s.bad'", err, filename)
+	}
+	os.Remove(written)
+	return fmt.Errorf("%v, file removed", err)
+}
+
+// expectedChecksum works out what Link should hash to, from --checksum,
+// --checksum-url or, with --checksum=auto, from files published next to it
+func (c *FlagsComponents) expectedChecksum(Link string, say func(string)) (*checksum, error) {
+	if entry := c.batchOptions[Link]; entry != nil && entry.checksum != nil {
+		return entry.checksum, nil
+	}
+	if c.Checksum != nil {
+		return c.Checksum, nil
+	}
+	// SUMS files list the decoded name, "a b.iso" rather than "a%20b.iso"
+	name := path.Base(strings.SplitN(Link, "?", 2)[0])
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	if c.ChecksumURL != "" {
+		sum, err := c.fetchChecksum(c.ChecksumURL, name, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get checksum from %s: %v", c.ChecksumURL, err)
+		}
+		return sum, nil
+	}
+	if !c.ChecksumAuto {
+		return nil, nil
+	}
+
+	u, err := url.Parse(Link)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery, u.Fragment = "", ""
+	candidates := []struct{ ref, algo string }{
+		{u.String() + ".sha256", "sha256"},
+		{u.String() + ".sha512", "sha512"},
+		{"SHA256SUMS", "sha256"},
+		{"SHA512SUMS", "sha512"},
+	}
+	for _, candidate := range candidates {
+		ref, err := u.Parse(candidate.ref)
+		if err != nil {
+			continue
+		}
+		if sum, err := c.fetchChecksum(ref.String(), name, candidate.algo); err == nil {
+			say(fmt.Sprintf("Using checksum from %s\n", ref))
+			return sum, nil
+		}
+	}
+	say("No checksum file found next to the URL, skipping verification.\n")
+	return nil, nil
+}
+
+// fetchChecksum downloads a checksum file, either a bare digest or
+// sha256sum style "<hex>  <name>" lines, and picks the entry for name
+func (c *FlagsComponents) fetchChecksum(link, name, algo string) (*checksum, error) {
+	req, err := c.newRequest("GET", link)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+	return pickChecksum(io.LimitReader(resp.Body, 1<<20), name, algo)
+}
+
+// pickChecksum finds the entry for name in a checksum file
+func pickChecksum(r io.Reader, name, algo string) (*checksum, error) {
+	scanner := bufio.NewScanner(r)
+	var single string
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		digest := strings.Fields(line)[0]
+		if digest == line {
+			single = digest
+			continue
+		}
+		// The name is the rest of the line, spaces and all, and
+		// sha256sum marks binary mode with a leading '*'
+		file := strings.TrimPrefix(strings.TrimSpace(line[len(digest):]), "*")
+		if file == name {
+			return checksumFromHex(digest, algo)
+		}
+	}
+	if single != "" {
+		return checksumFromHex(single, algo)
+	}
+	return nil, fmt.Errorf("no checksum for %s", name)
+}
+
+// checksumFromHex guesses the algorithm from the digest length when the file doesn't say
+func checksumFromHex(digest, algo string) (*checksum, error) {
+	if algo == "" {
+		switch len(digest) {
+		case 32:
+			algo = "md5"
+		case 40:
+			algo = "sha1"
+		case 64:
+			algo = "sha256"
+		case 128:
+			algo = "sha512"
+		default:
+			return nil, fmt.Errorf("unrecognised checksum %s", digest)
+		}
+	}
+	return parseChecksum(algo + ":" + digest)
+}
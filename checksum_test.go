@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	sumA = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	sumB = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+func TestParseChecksum(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"sha256:" + sumA, "sha256:" + sumA, true},
+		{"SHA256: " + strings.ToUpper(sumA) + " ", "sha256:" + sumA, true},
+		{"md5:900150983cd24fb0d6963f7d28e17f72", "md5:900150983cd24fb0d6963f7d28e17f72", true},
+		{"sha1:" + sumA, "", false},
+		{"sha256:xyz", "", false},
+		{"crc32:abcd", "", false},
+		{sumA, "", false},
+	}
+	for _, tt := range tests {
+		got, err := parseChecksum(tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseChecksum(%q) error %v, want ok %v", tt.in, err, tt.ok)
+			continue
+		}
+		if err == nil && got.String() != tt.want {
+			t.Errorf("parseChecksum(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestChecksumFromHex(t *testing.T) {
+	tests := []struct {
+		digest, algo, want string
+	}{
+		{"900150983cd24fb0d6963f7d28e17f72", "", "md5"},
+		{"a9993e364706816aba3e25717850c26c9cd0d89d", "", "sha1"},
+		{sumA, "", "sha256"},
+		{strings.Repeat("ab", 64), "", "sha512"},
+		{sumA, "sha256", "sha256"},
+		{"abcd", "", ""},
+		{sumA, "sha512", ""},
+	}
+	for _, tt := range tests {
+		got, err := checksumFromHex(tt.digest, tt.algo)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("checksumFromHex(%.8s…, %q) = %s, want an error", tt.digest, tt.algo, got)
+			}
+			continue
+		}
+		if err != nil || got.algo != tt.want {
+			t.Errorf("checksumFromHex(%.8s…, %q) = %v, %v, want %s", tt.digest, tt.algo, got, err, tt.want)
+		}
+	}
+}
+
+func TestPickChecksum(t *testing.T) {
+	sums := "# release sums\n" +
+		sumA + "  a.iso\n" +
+		sumB + " *my file.iso\n" +
+		sumA + "\tb.iso\n"
+	tests := []struct {
+		file, name, want string
+	}{
+		{sums, "a.iso", sumA},
+		{sums, "my file.iso", sumB},
+		{sums, "b.iso", sumA},
+		{sums, "file.iso", ""},
+		{sums, "c.iso", ""},
+		{sumB + "\n", "anything.iso", sumB},
+	}
+	for _, tt := range tests {
+		got, err := pickChecksum(strings.NewReader(tt.file), tt.name, "")
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("pickChecksum(%q) = %s, want no match", tt.name, got)
+			}
+			continue
+		}
+		if err != nil || got.String() != "sha256:"+tt.want {
+			t.Errorf("pickChecksum(%q) = %v, %v, want sha256:%s", tt.name, got, err, tt.want)
+		}
+	}
+}
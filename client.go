@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// newHTTPClient builds the client shared by single downloads and the mirror
+func (c *FlagsComponents) newHTTPClient() *http.Client {
+	transport := schemeTransport{
+		"http":  c.newTransport("http"),
+		"https": c.newTransport("https"),
+	}
+
+	// No overall Timeout here, it would cut off large files; stalls are
+	// caught by the read timeout and the low speed check instead
+	var base http.RoundTripper = transport
+	if c.ServerResponse || c.Debug {
+		base = &debugTransport{base: transport, c: c}
+	}
+	client := &http.Client{
+		Transport:     &authTransport{base: base, c: c},
+		CheckRedirect: c.checkRedirect,
+	}
+	if c.jar != nil {
+		client.Jar = c.jar
+	}
+	return client
+}
+
+// newTransport is the transport for URLs of one scheme. Its dialer knows
+// the scheme, which picks the SOCKS proxy from http_proxy or https_proxy
+func (c *FlagsComponents) newTransport(scheme string) *http.Transport {
+	transport := &http.Transport{
+		Proxy: c.httpProxy,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return c.dialContext(ctx, scheme, network, addr)
+		},
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: c.ReadTimeout,
+		// Without --compression Go asks for gzip and decodes it itself,
+		// with it acceptEncoding and decodeBody take over
+		DisableCompression: c.Compression != "",
+		TLSClientConfig:    c.tlsConfig,
+	}
+	if c.ConnectTimeout > 0 {
+		transport.TLSHandshakeTimeout = c.ConnectTimeout
+	}
+	return transport
+}
+
+// schemeTransport sends every request, redirect hops included, through the
+// transport for its own scheme
+type schemeTransport map[string]*http.Transport
+
+func (t schemeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport, ok := t[req.URL.Scheme]
+	if !ok {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("unsupported protocol scheme %q", req.URL.Scheme)
+	}
+	return transport.RoundTrip(req)
+}
+
+// context is the parent of every request, it expires with --deadline
+func (c *FlagsComponents) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// dialContext connects to addr for a URL of scheme, through a SOCKS proxy
+// when one is configured, and arms the read timeout on the connection
+func (c *FlagsComponents) dialContext(ctx context.Context, scheme, network, addr string) (net.Conn, error) {
+	var conn net.Conn
+	var err error
+	if p := c.socksProxyFor(scheme, addr); p != nil {
+		conn, err = c.dialSocks(ctx, p, network, addr)
+	} else {
+		conn, err = c.dialDirect(ctx, network, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.ReadTimeout > 0 {
+		return &readTimeoutConn{Conn: conn, timeout: c.ReadTimeout}, nil
+	}
+	return conn, nil
+}
+
+// dialDirect resolves and connects with their own timeouts
+func (c *FlagsComponents) dialDirect(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	ips, err := c.lookupIP(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := net.Dialer{Timeout: c.ConnectTimeout, KeepAlive: 30 * time.Second}
+	var lastErr error
+	for _, ip := range ips {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// lookupIP resolves host within --dns-timeout
+func (c *FlagsComponents) lookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	if c.DNSTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.DNSTimeout)
+		defer cancel()
+	}
+	return net.DefaultResolver.LookupIP(ctx, "ip", host)
+}
+
+// readTimeoutConn fails a read that gets no data for longer than timeout
+type readTimeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (r *readTimeoutConn) Read(p []byte) (int, error) {
+	r.Conn.SetReadDeadline(time.Now().Add(r.timeout))
+	return r.Conn.Read(p)
+}
+
+// lowSpeedError is a transfer that stayed under --low-speed-limit for --low-speed-time
+type lowSpeedError struct {
+	limit  int64
+	window time.Duration
+}
+
+func (e *lowSpeedError) Error() string {
+	return fmt.Sprintf("transfer speed stayed below %d bytes/s for %s", e.limit, e.window)
+}
+
+// lowSpeedBody wraps a response body and closes it once the transfer has
+// been too slow for too long, so a stalled read returns instead of hanging
+type lowSpeedBody struct {
+	body    io.ReadCloser
+	count   atomic.Int64
+	aborted atomic.Pointer[lowSpeedError]
+	done    chan struct{}
+	once    sync.Once
+}
+
+// watchSpeed applies the --low-speed-limit/--low-speed-time check to body
+func (c *FlagsComponents) watchSpeed(body io.ReadCloser) io.ReadCloser {
+	if c.LowSpeedLimit <= 0 {
+		return body
+	}
+	window := c.LowSpeedTime
+	if window <= 0 {
+		window = 30 * time.Second
+	}
+	l := &lowSpeedBody{body: body, done: make(chan struct{})}
+	go l.watch(c.LowSpeedLimit, window)
+	return l
+}
+
+func (l *lowSpeedBody) watch(limit int64, window time.Duration) {
+	// Taken before the ticker starts so n ticks later n seconds have passed
+	last := time.Now()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	var slowSince time.Time
+	for {
+		select {
+		case <-l.done:
+			return
+		case now := <-ticker.C:
+			speed := float64(l.count.Swap(0)) / now.Sub(last).Seconds()
+			if speed >= float64(limit) {
+				slowSince, last = time.Time{}, now
+				continue
+			}
+			// The slow stretch began at the previous tick, not this one
+			if slowSince.IsZero() {
+				slowSince = last
+			}
+			last = now
+			if now.Sub(slowSince) >= window {
+				l.aborted.Store(&lowSpeedError{limit: limit, window: window})
+				l.body.Close()
+				return
+			}
+		}
+	}
+}
+
+func (l *lowSpeedBody) Read(p []byte) (int, error) {
+	n, err := l.body.Read(p)
+	l.count.Add(int64(n))
+	if err != nil {
+		if aborted := l.aborted.Load(); aborted != nil {
+			return n, aborted
+		}
+	}
+	return n, err
+}
+
+func (l *lowSpeedBody) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return l.body.Close()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingBody is a response body that never sends anything, a Read only
+// returns once the body is closed
+type blockingBody struct {
+	once   sync.Once
+	closed chan struct{}
+}
+
+func (b *blockingBody) Read(p []byte) (int, error) {
+	<-b.closed
+	return 0, errors.New("read on closed body")
+}
+
+func (b *blockingBody) Close() error {
+	b.once.Do(func() { close(b.closed) })
+	return nil
+}
+
+func TestLowSpeedBody(t *testing.T) {
+	tests := []struct {
+		window   time.Duration
+		min, max time.Duration
+	}{
+		// The first tick already covers a whole slow second
+		{time.Second, 900 * time.Millisecond, 1500 * time.Millisecond},
+		{2 * time.Second, 1900 * time.Millisecond, 2500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		c := &FlagsComponents{LowSpeedLimit: 1000, LowSpeedTime: tt.window}
+		body := c.watchSpeed(&blockingBody{closed: make(chan struct{})})
+		start := time.Now()
+		_, err := body.Read(make([]byte, 10))
+		took := time.Since(start)
+		body.Close()
+
+		var slow *lowSpeedError
+		if !errors.As(err, &slow) {
+			t.Errorf("--low-speed-time=%s: Read error %v, want a lowSpeedError", tt.window, err)
+		}
+		if took < tt.min || took > tt.max {
+			t.Errorf("--low-speed-time=%s: aborted after %s, want between %s and %s", tt.window, took, tt.min, tt.max)
+		}
+	}
+}
+
+func TestLowSpeedBodyOff(t *testing.T) {
+	body := &blockingBody{closed: make(chan struct{})}
+	c := &FlagsComponents{LowSpeedTime: time.Second}
+	if got := c.watchSpeed(body); got != io.ReadCloser(body) {
+		t.Error("the body was wrapped without --low-speed-limit")
+	}
+}
+
+func TestReadTimeoutConn(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	conn := &readTimeoutConn{Conn: client, timeout: 200 * time.Millisecond}
+	defer conn.Close()
+
+	// Every read gets a fresh deadline, so data that keeps coming in
+	// within the timeout never trips it however long the transfer takes
+	go func() {
+		for range 4 {
+			time.Sleep(120 * time.Millisecond)
+			server.Write([]byte("x"))
+		}
+	}()
+	buf := make([]byte, 1)
+	for i := range 4 {
+		if _, err := conn.Read(buf); err != nil {
+			t.Fatalf("read %d: %v", i, err)
+		}
+	}
+
+	start := time.Now()
+	_, err := conn.Read(buf)
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("a stalled read returned %v, want a deadline error", err)
+	}
+	if took := time.Since(start); took < 150*time.Millisecond || took > time.Second {
+		t.Errorf("a stalled read gave up after %s, want about 200ms", took)
+	}
+}
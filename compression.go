@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// acceptEncoding asks for a compressed response per --compression. Range
+// requests never do, offsets only make sense in the plain representation
+func (c *FlagsComponents) acceptEncoding(req *http.Request) {
+	if req.Header.Get("Accept-Encoding") != "" || req.Header.Get("Range") != "" {
+		// --header wins
+		return
+	}
+	switch c.Compression {
+	case "auto":
+		req.Header.Set("Accept-Encoding", "gzip, deflate")
+	case "gzip":
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+}
+
+// plainLength is the Content-Length when it is the size of the file itself,
+// -1 when the body is encoded and the real size is unknown
+func plainLength(resp *http.Response) int64 {
+	if resp.Header.Get("Content-Encoding") != "" {
+		return -1
+	}
+	return resp.ContentLength
+}
+
+// decompressedSizeError is a body that expanded past --max-decompressed-size
+type decompressedSizeError struct {
+	limit int64
+}
+
+func (e *decompressedSizeError) Error() string {
+	return fmt.Sprintf("decompressed body exceeds --max-decompressed-size of %s, aborting", humanSize(float64(e.limit)))
+}
+
+// decodedBody undoes Content-Encoding on the fly and counts the bytes on
+// both sides, so the log can show what went over the wire
+type decodedBody struct {
+	encoding string
+	raw      io.ReadCloser
+	decoder  io.Reader
+	encoded  int64
+	decoded  int64
+	limit    int64
+}
+
+// decodeBody swaps resp.Body for a decoding reader when the response is
+// gzip or deflate encoded. It returns nil when the body is left as is
+func (c *FlagsComponents) decodeBody(resp *http.Response) (*decodedBody, error) {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	// Without --compression the transport already decoded gzip, with
+	// --compression=none we never asked and an encoded body is saved as sent
+	if c.Compression == "" || c.Compression == "none" || c.KeepEncoded {
+		return nil, nil
+	}
+	if encoding != "gzip" && encoding != "x-gzip" && encoding != "deflate" {
+		return nil, nil
+	}
+
+	body := &decodedBody{encoding: encoding, raw: resp.Body, limit: c.MaxDecompressedSize}
+	src := bufio.NewReader(&encodedCounter{r: resp.Body, n: &body.encoded})
+	switch encoding {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(src)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode gzip response: %v", err)
+		}
+		body.decoder = zr
+	case "deflate":
+		// "deflate" should be zlib wrapped, some servers send raw deflate
+		if header, err := src.Peek(2); err == nil && header[0]&0x0f == 8 && (int(header[0])<<8|int(header[1]))%31 == 0 {
+			zr, err := zlib.NewReader(src)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode deflate response: %v", err)
+			}
+			body.decoder = zr
+		} else {
+			body.decoder = flate.NewReader(src)
+		}
+	}
+	resp.Body = body
+	return body, nil
+}
+
+func (b *decodedBody) Read(p []byte) (int, error) {
+	n, err := b.decoder.Read(p)
+	if b.limit > 0 && b.decoded+int64(n) > b.limit {
+		// Hand over no more than the limit, nothing past it gets written
+		n = int(b.limit - b.decoded)
+		b.decoded = b.limit
+		return n, &decompressedSizeError{limit: b.limit}
+	}
+	b.decoded += int64(n)
+	return n, err
+}
+
+func (b *decodedBody) Close() error {
+	if closer, ok := b.decoder.(io.Closer); ok {
+		closer.Close()
+	}
+	return b.raw.Close()
+}
+
+// String is the log line once the body has been read
+func (b *decodedBody) String() string {
+	return fmt.Sprintf("Decoded %s: %d bytes received, %d bytes after decompression\n", b.encoding, b.encoded, b.decoded)
+}
+
+type encodedCounter struct {
+	r io.Reader
+	n *int64
+}
+
+func (e *encodedCounter) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	*e.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func gzipResponse(t *testing.T, plain string) *http.Response {
+	t.Helper()
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	zw.Write([]byte(plain))
+	zw.Close()
+	return &http.Response{
+		Header: http.Header{"Content-Encoding": {"gzip"}},
+		Body:   io.NopCloser(&b),
+	}
+}
+
+func TestDecodeBodyLimit(t *testing.T) {
+	plain := strings.Repeat("0123456789", 1000)
+	tests := []struct {
+		limit int64
+		want  int
+		fails bool
+	}{
+		{0, len(plain), false},
+		{int64(len(plain)), len(plain), false},
+		{4000, 4000, true},
+		{1, 1, true},
+	}
+	for _, tt := range tests {
+		c := &FlagsComponents{Compression: "auto", MaxDecompressedSize: tt.limit}
+		resp := gzipResponse(t, plain)
+		decoded, err := c.decodeBody(resp)
+		if err != nil || decoded == nil {
+			t.Fatalf("decodeBody = %v, %v", decoded, err)
+		}
+		var out bytes.Buffer
+		// A small buffer makes the limit fall inside a read
+		_, err = io.CopyBuffer(&out, struct{ io.Reader }{resp.Body}, make([]byte, 333))
+		var sizeErr *decompressedSizeError
+		if errors.As(err, &sizeErr) != tt.fails {
+			t.Errorf("limit %d: error %v, want failure %v", tt.limit, err, tt.fails)
+		}
+		if out.Len() != tt.want || out.String() != plain[:out.Len()] {
+			t.Errorf("limit %d: wrote %d bytes, want %d", tt.limit, out.Len(), tt.want)
+		}
+	}
+}
+
+func TestDecodeBodyLeavesPlainBodies(t *testing.T) {
+	for _, compression := range []string{"", "none"} {
+		c := &FlagsComponents{Compression: compression}
+		if decoded, _ := c.decodeBody(gzipResponse(t, "x")); decoded != nil {
+			t.Errorf("--compression=%q decoded the body", compression)
+		}
+	}
+	c := &FlagsComponents{Compression: "auto"}
+	resp := gzipResponse(t, "x")
+	resp.Header.Del("Content-Encoding")
+	if decoded, _ := c.decodeBody(resp); decoded != nil {
+		t.Error("a body without Content-Encoding was decoded")
+	}
+}
+
+func TestTransportCompression(t *testing.T) {
+	tests := map[string]bool{"": false, "none": true, "auto": true, "gzip": true}
+	for compression, disabled := range tests {
+		c := &FlagsComponents{Compression: compression}
+		if got := c.newTransport("http").DisableCompression; got != disabled {
+			t.Errorf("--compression=%q: DisableCompression = %v, want %v", compression, got, disabled)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// cookieJar is the standard jar plus a record of every cookie it was given,
+// which the standard jar can't list, so they can be written back out
+type cookieJar struct {
+	*cookiejar.Jar
+	mu      sync.Mutex
+	entries map[string]*jarEntry
+}
+
+type jarEntry struct {
+	cookie   *http.Cookie
+	domain   string
+	hostOnly bool
+}
+
+func newCookieJar() (*cookieJar, error) {
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, err
+	}
+	return &cookieJar{Jar: jar, entries: make(map[string]*jarEntry)}, nil
+}
+
+func (j *cookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.Jar.SetCookies(u, cookies)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, received := range cookies {
+		cookie := *received
+		if cookie.MaxAge > 0 {
+			// Max-Age counts from now, pin it down so it can be saved
+			cookie.Expires = time.Now().Add(time.Duration(cookie.MaxAge) * time.Second)
+			cookie.MaxAge = 0
+		}
+		// Only what the standard jar accepted is saved, a Domain naming
+		// another site or a public suffix must not come back from the file
+		domain, hostOnly, ok := cookieDomain(u.Hostname(), cookie.Domain)
+		if !ok {
+			continue
+		}
+		entry := &jarEntry{cookie: &cookie, domain: domain, hostOnly: hostOnly}
+		if cookie.Path == "" || !strings.HasPrefix(cookie.Path, "/") {
+			// Default path is the directory of the request path
+			cookie.Path = "/"
+			if i := strings.LastIndex(u.Path, "/"); i > 0 {
+				cookie.Path = u.Path[:i]
+			}
+		}
+		key := entry.domain + ";" + cookie.Path + ";" + cookie.Name
+		if cookie.MaxAge < 0 || (!cookie.Expires.IsZero() && cookie.Expires.Before(time.Now())) {
+			delete(j.entries, key)
+			continue
+		}
+		j.entries[key] = entry
+	}
+}
+
+// cookieDomain applies the Domain attribute rules of net/http/cookiejar to
+// a cookie set by host: the domain it is stored under, whether it is sent
+// to that host only, and false when the jar rejects it
+func cookieDomain(host, attr string) (string, bool, bool) {
+	host = strings.ToLower(host)
+	if attr == "" {
+		return host, true, true
+	}
+	domain := strings.TrimPrefix(strings.ToLower(attr), ".")
+	if domain == "" || strings.HasSuffix(domain, ".") {
+		return "", false, false
+	}
+	if net.ParseIP(host) != nil {
+		// An IP address can only set cookies for itself
+		return host, true, host == domain
+	}
+	// A public suffix such as co.uk is only allowed for that very host
+	if ps, _ := publicsuffix.PublicSuffix(domain); ps == domain {
+		return host, true, host == domain
+	}
+	if host != domain && !strings.HasSuffix(host, "."+domain) {
+		return "", false, false
+	}
+	return domain, false, true
+}
+
+// load reads a Netscape cookies.txt file, the format browsers export
+func (j *cookieJar) load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to load cookies: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		httpOnly := false
+		if rest, ok := strings.CutPrefix(line, "#HttpOnly_"); ok {
+			line, httpOnly = rest, true
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			continue
+		}
+		domain, subdomains, path, secure, expires, name, value := fields[0], fields[1], fields[2], fields[3], fields[4], fields[5], fields[6]
+
+		cookie := &http.Cookie{
+			Name:     name,
+			Value:    value,
+			Path:     path,
+			Secure:   strings.EqualFold(secure, "TRUE"),
+			HttpOnly: httpOnly,
+		}
+		if secs, err := strconv.ParseInt(expires, 10, 64); err == nil && secs > 0 {
+			cookie.Expires = time.Unix(secs, 0)
+			if cookie.Expires.Before(time.Now()) {
+				continue
+			}
+		}
+		host := strings.TrimPrefix(domain, ".")
+		if strings.EqualFold(subdomains, "TRUE") {
+			cookie.Domain = host
+		}
+		scheme := "http"
+		if cookie.Secure {
+			scheme = "https"
+		}
+		j.SetCookies(&url.URL{Scheme: scheme, Host: host, Path: path}, []*http.Cookie{cookie})
+	}
+	return scanner.Err()
+}
+
+// save writes the jar in cookies.txt format, session cookies only when asked to keep them
+func (j *cookieJar) save(path string, keepSession bool) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	keys := make([]string, 0, len(j.entries))
+	for key := range j.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString("# HTTP Cookie File\n# Generated by go-wget. Edit at your own risk.\n\n")
+	now := time.Now()
+	for _, key := range keys {
+		entry := j.entries[key]
+		cookie := entry.cookie
+
+		var expires int64
+		if !cookie.Expires.IsZero() {
+			if cookie.Expires.Before(now) {
+				continue
+			}
+			expires = cookie.Expires.Unix()
+		} else if !keepSession {
+			continue
+		}
+
+		domain, subdomains := entry.domain, "FALSE"
+		if !entry.hostOnly {
+			domain, subdomains = "."+entry.domain, "TRUE"
+		}
+		if cookie.HttpOnly {
+			domain = "#HttpOnly_" + domain
+		}
+		secure := "FALSE"
+		if cookie.Secure {
+			secure = "TRUE"
+		}
+		fmt.Fprintf(&b, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", domain, subdomains, cookie.Path, secure, expires, cookie.Name, cookie.Value)
+	}
+
+	if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+		return fmt.Errorf("failed to save cookies: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCookieDomain(t *testing.T) {
+	tests := []struct {
+		host, attr   string
+		domain       string
+		hostOnly, ok bool
+	}{
+		{"www.example.com", "", "www.example.com", true, true},
+		{"WWW.Example.com", "", "www.example.com", true, true},
+		{"www.example.com", "example.com", "example.com", false, true},
+		{"www.example.com", ".example.com", "example.com", false, true},
+		{"www.example.com", "www.example.com", "www.example.com", false, true},
+		{"example.com", "example.com", "example.com", false, true},
+		// Another site, or a parent that only shares a suffix
+		{"www.example.com", "evil.com", "", false, false},
+		{"www.example.com", "ample.com", "", false, false},
+		{"example.com", "www.example.com", "", false, false},
+		// Public suffixes
+		{"www.example.com", "com", "", false, false},
+		{"www.example.co.uk", "co.uk", "", false, false},
+		{"co.uk", "co.uk", "co.uk", true, true},
+		// IP addresses only set cookies for themselves
+		{"127.0.0.1", "127.0.0.1", "127.0.0.1", true, true},
+		{"127.0.0.1", "0.0.1", "", false, false},
+		{"www.example.com", "example.com.", "", false, false},
+	}
+	for _, tt := range tests {
+		domain, hostOnly, ok := cookieDomain(tt.host, tt.attr)
+		if ok != tt.ok || ok && (domain != tt.domain || hostOnly != tt.hostOnly) {
+			t.Errorf("cookieDomain(%q, %q) = %q, %v, %v, want %q, %v, %v",
+				tt.host, tt.attr, domain, hostOnly, ok, tt.domain, tt.hostOnly, tt.ok)
+		}
+	}
+}
+
+func TestCookieJarSkipsRejectedCookies(t *testing.T) {
+	jar, err := newCookieJar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse("http://www.example.com/dir/page")
+	expires := time.Now().Add(time.Hour)
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "good", Value: "1", Domain: "example.com", Expires: expires},
+		{Name: "foreign", Value: "2", Domain: "victim.org", Expires: expires},
+		{Name: "suffix", Value: "3", Domain: "com", Expires: expires},
+	})
+
+	path := filepath.Join(t.TempDir(), "cookies.txt")
+	if err := jar.save(path, false); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := string(data)
+	if !strings.Contains(saved, ".example.com\tTRUE\t/dir\tFALSE\t") || !strings.Contains(saved, "\tgood\t1\n") {
+		t.Errorf("accepted cookie not saved:\n%s", saved)
+	}
+	if strings.Contains(saved, "foreign") || strings.Contains(saved, "suffix") {
+		t.Errorf("rejected cookie saved:\n%s", saved)
+	}
+}
+
+func TestCookieJarLoadSave(t *testing.T) {
+	dir := t.TempDir()
+	exp := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	in := filepath.Join(dir, "in.txt")
+	lines := []string{
+		"# Netscape HTTP Cookie File",
+		"",
+		".example.com\tTRUE\t/\tFALSE\t" + exp + "\tdomain\ta",
+		"host.example.com\tFALSE\t/path\tTRUE\t" + exp + "\thost\tb",
+		"#HttpOnly_.example.com\tTRUE\t/\tFALSE\t" + exp + "\thttponly\tc",
+		".example.com\tTRUE\t/\tFALSE\t1\texpired\td",
+		".example.com\tTRUE\t/\tFALSE\t0\tsession\te",
+		"malformed line",
+	}
+	if err := os.WriteFile(in, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	jar, err := newCookieJar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := jar.load(in); err != nil {
+		t.Fatal(err)
+	}
+
+	u, _ := url.Parse("https://host.example.com/path/file")
+	sent := map[string]string{}
+	for _, cookie := range jar.Cookies(u) {
+		sent[cookie.Name] = cookie.Value
+	}
+	want := map[string]string{"domain": "a", "host": "b", "httponly": "c", "session": "e"}
+	for name, value := range want {
+		if sent[name] != value {
+			t.Errorf("cookie %s = %q, want %q", name, sent[name], value)
+		}
+	}
+	if _, ok := sent["expired"]; ok {
+		t.Error("expired cookie was loaded")
+	}
+
+	out := filepath.Join(dir, "out.txt")
+	for _, keepSession := range []bool{false, true} {
+		if err := jar.save(out, keepSession); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		saved := string(data)
+		for _, line := range []string{
+			".example.com\tTRUE\t/\tFALSE\t" + exp + "\tdomain\ta",
+			"host.example.com\tFALSE\t/path\tTRUE\t" + exp + "\thost\tb",
+			"#HttpOnly_.example.com\tTRUE\t/\tFALSE\t" + exp + "\thttponly\tc",
+		} {
+			if !strings.Contains(saved, line+"\n") {
+				t.Errorf("save(keepSession=%v) is missing %q:\n%s", keepSession, line, saved)
+			}
+		}
+		if got := strings.Contains(saved, "\tsession\te"); got != keepSession {
+			t.Errorf("save(keepSession=%v) wrote the session cookie: %v", keepSession, got)
+		}
+	}
+}
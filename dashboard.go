@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// dashboard owns the terminal while several transfers run at once, for -i
+// and --mirror: one line per transfer and a footer with the totals, redrawn
+// in place under the log lines. Everything printed goes through Write so
+// log lines end up above the dashboard instead of inside it
+type dashboard struct {
+	c      *FlagsComponents
+	out    io.Writer
+	width  int
+	height int
+
+	mu      sync.Mutex
+	rows    []*progress
+	drawn   int    // lines of the last frame, erased before anything else is written
+	pending []byte // text still waiting for its newline
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// startDashboard takes over Stdout (and Stderr on the same terminal) when
+// the bar would be drawn, the returned func gives them back
+func (c *FlagsComponents) startDashboard() func() {
+	if c.Background || (c.Progress != "" && c.Progress != "bar") || !isTerminal(Stdout) || c.eventsOnTerminal() {
+		return func() {}
+	}
+	d := &dashboard{c: c, out: Stdout, width: terminalWidth(Stdout), height: 24, stop: make(chan struct{}), done: make(chan struct{})}
+	if f, ok := Stdout.(*os.File); ok {
+		if _, height, err := term.GetSize(int(f.Fd())); err == nil && height > 0 {
+			d.height = height
+		}
+	}
+	stdout, stderr := Stdout, Stderr
+	Stdout = d
+	if isTerminal(os.Stderr) {
+		Stderr = d
+	}
+	c.dash = d
+	go d.run()
+
+	return func() {
+		close(d.stop)
+		<-d.done
+		d.mu.Lock()
+		d.erase()
+		d.out.Write(d.pending)
+		d.pending = nil
+		d.mu.Unlock()
+		Stdout, Stderr = stdout, stderr
+		c.dash = nil
+	}
+}
+
+func (d *dashboard) run() {
+	defer close(d.done)
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+			d.mu.Lock()
+			d.redraw(nil)
+			d.mu.Unlock()
+		}
+	}
+}
+
+// Write prints whole lines above the dashboard. Progress drawn with \r by
+// anyone else would land in the middle of it, so partial lines wait
+func (d *dashboard) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pending = append(d.pending, p...)
+	i := bytes.LastIndexByte(d.pending, '\n')
+	if i < 0 {
+		return len(p), nil
+	}
+	d.redraw(d.pending[:i+1])
+	d.pending = append(d.pending[:0], d.pending[i+1:]...)
+	return len(p), nil
+}
+
+func (d *dashboard) add(p *progress) {
+	d.mu.Lock()
+	d.rows = append(d.rows, p)
+	d.mu.Unlock()
+}
+
+func (d *dashboard) remove(p *progress) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, row := range d.rows {
+		if row == p {
+			d.rows = append(d.rows[:i], d.rows[i+1:]...)
+			break
+		}
+	}
+	d.redraw(nil)
+}
+
+// fitWidth cuts s to less than width columns, the last column is left free
+// so a full line never wraps on terminals that wrap early
+func fitWidth(s string, width int) string {
+	limit := max(width-1, 1)
+	if utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	return string([]rune(s)[:limit])
+}
+
+// erase moves back to the first line of the last frame and clears it away
+func (d *dashboard) erase() {
+	if d.drawn > 0 {
+		fmt.Fprintf(d.out, "\x1b[%dA\x1b[J", d.drawn)
+		d.drawn = 0
+	}
+}
+
+// redraw replaces the frame, with log lines printed above it first. It is
+// written in one go so the terminal doesn't flicker
+func (d *dashboard) redraw(lines []byte) {
+	var frame bytes.Buffer
+	if d.drawn > 0 {
+		fmt.Fprintf(&frame, "\x1b[%dA\x1b[J", d.drawn)
+	}
+	frame.Write(lines)
+
+	var speed float64
+	shown := d.rows
+	// Leave room for the footer and a line of log on a small terminal
+	if limit := d.height - 3; limit > 0 && len(shown) > limit {
+		shown = shown[:limit]
+	}
+	for _, row := range d.rows {
+		row.mu.Lock()
+		speed += row.currentSpeed(row.written)
+		row.mu.Unlock()
+	}
+	// Every line of the frame has to stay one terminal line, or the cursor
+	// goes back up too little and the next frame is drawn over the log.
+	// Log lines above it may wrap, they are never erased
+	for _, row := range shown {
+		row.mu.Lock()
+		frame.WriteString(fitWidth(row.bar(row.written, false), d.width) + "\n")
+		row.mu.Unlock()
+	}
+	footer := fmt.Sprintf("%d active, %d queued, %s retrieved, %s",
+		len(d.rows), d.c.pending.Load(), humanSize(float64(d.c.retrieved.Load())), strings.TrimSpace(formatSpeed(speed/(1024*1024))))
+	if hidden := len(d.rows) - len(shown); hidden > 0 {
+		footer += fmt.Sprintf(" (%d more not shown)", hidden)
+	}
+	frame.WriteString(fitWidth(footer, d.width) + "\n")
+	d.drawn = len(shown) + 1
+	d.out.Write(frame.Bytes())
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+var eraseSeq = regexp.MustCompile(`\x1b\[(\d+)A\x1b\[J`)
+
+func TestDashboardFrameFitsTerminal(t *testing.T) {
+	for _, width := range []int{20, 40, 61, 100} {
+		var out bytes.Buffer
+		d := &dashboard{c: &FlagsComponents{}, out: &out, width: width, height: 24}
+		names := []string{"a.bin", "a-file-with-a-very-long-name.tar.gz", "ünïcödé-nämé-thät-is-löng.iso"}
+		for i, name := range names {
+			d.rows = append(d.rows, &progress{name: name, total: 1 << 20, width: width, nameWidth: 20,
+				start: time.Now().Add(-time.Second), written: int64(i+1) << 18})
+		}
+		d.rows = append(d.rows, &progress{name: "unknown", total: -1, width: width, nameWidth: 20, start: time.Now()})
+
+		long := strings.Repeat("log line that is longer than the terminal ", 5)
+		d.Write([]byte(long + "\n"))
+		d.Write([]byte("partial "))
+		d.redraw(nil)
+
+		frames := eraseSeq.Split(out.String(), -1)
+		matches := eraseSeq.FindAllStringSubmatch(out.String(), -1)
+		if len(matches) != 1 || matches[0][1] != fmt.Sprint(len(d.rows)+1) {
+			t.Fatalf("width %d: erase sequences %q, want one going up %d lines", width, matches, len(d.rows)+1)
+		}
+		last := strings.TrimSuffix(frames[len(frames)-1], "\n")
+		lines := strings.Split(last, "\n")
+		if len(lines) != d.drawn {
+			t.Errorf("width %d: frame has %d lines, drawn says %d", width, len(lines), d.drawn)
+		}
+		for _, line := range lines {
+			if n := utf8.RuneCountInString(line); n >= width {
+				t.Errorf("width %d: %d column line would wrap: %q", width, n, line)
+			}
+		}
+		// The log line itself is printed whole above the first frame
+		if !strings.Contains(frames[0], long) {
+			t.Errorf("width %d: log line was cut", width)
+		}
+	}
+}
+
+func TestDashboardLimitsRowsToHeight(t *testing.T) {
+	var out bytes.Buffer
+	d := &dashboard{c: &FlagsComponents{}, out: &out, width: 80, height: 6}
+	for i := range 10 {
+		d.rows = append(d.rows, &progress{name: fmt.Sprintf("f%d", i), total: 100, width: 80, nameWidth: 20, start: time.Now()})
+	}
+	d.redraw(nil)
+	if d.drawn != 4 {
+		t.Errorf("drew %d lines on a 6 line terminal, want 3 rows and the footer", d.drawn)
+	}
+	if !strings.Contains(out.String(), "(7 more not shown)") {
+		t.Errorf("footer doesn't count the hidden rows:\n%s", out.String())
+	}
+}
+
+func TestFitWidth(t *testing.T) {
+	tests := []struct {
+		in    string
+		width int
+		want  string
+	}{
+		{"short", 80, "short"},
+		{"exactly10!", 11, "exactly10!"},
+		{"exactly10!", 10, "exactly10"},
+		{"ääääää", 4, "äää"},
+		{"abc", 0, "a"},
+	}
+	for _, tt := range tests {
+		if got := fitWidth(tt.in, tt.width); got != tt.want {
+			t.Errorf("fitWidth(%q, %d) = %q, want %q", tt.in, tt.width, got, tt.want)
+		}
+	}
+}
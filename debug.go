@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// redactedHeaders never show up in -S or -d output
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization"}
+
+// debugTransport prints what goes over the wire for every hop, redirects
+// and authentication retries included: the request with -d, the response
+// headers with -S or -d. It sits under authTransport to see what is sent
+type debugTransport struct {
+	base http.RoundTripper
+	c    *FlagsComponents
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.c.Debug {
+		t.c.say("---request begin---\n" + formatRequest(req) + "---request end---\n")
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if t.c.Debug {
+		t.c.say("---response begin---\n" + formatResponse(resp) + "---response end---\n")
+	} else if t.c.ServerResponse {
+		// wget indents the server's headers so they stand out from our own lines
+		t.c.say("  " + strings.ReplaceAll(strings.TrimRight(formatResponse(resp), "\n"), "\n", "\n  ") + "\n")
+	}
+	return resp, nil
+}
+
+// formatRequest is the request line and headers roughly as sent
+func formatRequest(req *http.Request) string {
+	header := req.Header.Clone()
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	header.Set("Host", host)
+	if req.ContentLength > 0 {
+		header.Set("Content-Length", fmt.Sprint(req.ContentLength))
+	}
+	return fmt.Sprintf("%s %s HTTP/1.1\n", req.Method, req.URL.RequestURI()) + formatHeader(header) + "\n"
+}
+
+// formatResponse is the status line and headers
+func formatResponse(resp *http.Response) string {
+	return fmt.Sprintf("%s %s\n", resp.Proto, resp.Status) + formatHeader(resp.Header) + "\n"
+}
+
+func formatHeader(header http.Header) string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		for _, value := range header[name] {
+			for _, redacted := range redactedHeaders {
+				if !strings.EqualFold(name, redacted) {
+					continue
+				}
+				// Keep the scheme, unless there is none and it is all secret
+				if scheme, _, ok := strings.Cut(strings.TrimSpace(value), " "); ok {
+					value = scheme + " <redacted>"
+				} else {
+					value = "<redacted>"
+				}
+			}
+			fmt.Fprintf(&b, "%s: %s\n", name, value)
+		}
+	}
+	return b.String()
+}
+
+// rawHeaders is the response head as --save-headers puts it in front of the file
+func rawHeaders(resp *http.Response) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s\r\n", resp.Proto, resp.Status)
+	resp.Header.Write(&b)
+	b.WriteString("\r\n")
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFormatHeaderRedacts(t *testing.T) {
+	tests := []struct {
+		name, value, want string
+	}{
+		{"Authorization", "Basic dXNlcjpzZWNyZXQ=", "Authorization: Basic <redacted>\n"},
+		{"Authorization", `Digest username="user", response="6629fae49393a05397450978507c4ef1"`, "Authorization: Digest <redacted>\n"},
+		{"Proxy-Authorization", "Basic dXNlcjpzZWNyZXQ=", "Proxy-Authorization: Basic <redacted>\n"},
+		{"authorization", "Bearer secret-token", "authorization: Bearer <redacted>\n"},
+		{"proxy-authorization", "Basic dXNlcjpzZWNyZXQ=", "proxy-authorization: Basic <redacted>\n"},
+		{"Authorization", "secret-token", "Authorization: <redacted>\n"},
+		{"Accept", "*/*", "Accept: */*\n"},
+	}
+	for _, tt := range tests {
+		// Set directly so lower-case keys stay as they are
+		got := formatHeader(http.Header{tt.name: {tt.value}})
+		if got != tt.want {
+			t.Errorf("formatHeader(%s: %s) = %q, want %q", tt.name, tt.value, got, tt.want)
+		}
+		if strings.Contains(got, "secret") || strings.Contains(got, "dXNlcjpzZWNyZXQ=") {
+			t.Errorf("formatHeader(%s) leaked the credentials: %q", tt.name, got)
+		}
+	}
+}
+
+func TestFormatRequest(t *testing.T) {
+	req, _ := http.NewRequest("GET", "http://example.com/a/b?x=1", nil)
+	req.Header.Set("Authorization", "Basic dXNlcjpzZWNyZXQ=")
+	req.Header.Set("User-Agent", "wget")
+	want := "GET /a/b?x=1 HTTP/1.1\n" +
+		"Authorization: Basic <redacted>\n" +
+		"Host: example.com\n" +
+		"User-Agent: wget\n\n"
+	if got := formatRequest(req); got != want {
+		t.Errorf("formatRequest = %q, want %q", got, want)
+	}
+	if req.Header.Get("Host") != "" {
+		t.Error("formatRequest changed the request's headers")
+	}
+}
+
+func TestRawHeaders(t *testing.T) {
+	resp := &http.Response{
+		Proto:  "HTTP/1.1",
+		Status: "200 OK",
+		Header: http.Header{
+			"Content-Type":   {"text/plain"},
+			"Content-Length": {"5"},
+			"Set-Cookie":     {"a=1", "b=2"},
+		},
+		Body: io.NopCloser(strings.NewReader("hello")),
+	}
+	want := "HTTP/1.1 200 OK\r\n" +
+		"Content-Length: 5\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"Set-Cookie: a=1\r\n" +
+		"Set-Cookie: b=2\r\n" +
+		"\r\n"
+	if got := rawHeaders(resp); got != want {
+		t.Errorf("rawHeaders = %q, want %q", got, want)
+	}
+}
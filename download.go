@@ -1,41 +1,122 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"time"
 )
 
-func DownloadFiles(args *FlagsComponents) error {
+func DownloadFiles(args *FlagsComponents) (err error) {
 	if err := args.Validate(); err != nil {
 		return err
-	} 
+	}
+	// With -O - stdout carries the download, everything else goes to stderr
+	if args.toStdout() || args.EventsFD == 1 {
+		Stdout = os.Stderr
+	}
+	// -B starts the same command again detached and leaves the work to it
+	if args.Background && os.Getenv(backgroundLogEnv) == "" {
+		return args.detach()
+	}
+	if args.PIDFile != "" {
+		if err := writePIDFile(args.PIDFile, os.Getpid()); err != nil {
+			return err
+		}
+		defer removePIDFile(args.PIDFile)
+	}
+	if args.EventsFD > 0 || args.Report != "" {
+		if err := args.openEvents(); err != nil {
+			return err
+		}
+		if args.Report != "" {
+			defer func() {
+				if reportErr := args.writeReport(err); reportErr != nil {
+					fmt.Fprintln(os.Stderr, reportErr)
+				}
+			}()
+		}
+	}
+	// --deadline bounds the whole run, every request hangs off this context
+	ctx := context.Background()
+	if args.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, args.Deadline)
+		defer cancel()
+	}
+	args.ctx = ctx
+	if args.AskPassword {
+		if err := args.askPassword(); err != nil {
+			return err
+		}
+	}
+
+	// One cookie jar for the whole run, unless --no-cookies
+	if !args.NoCookies {
+		jar, err := newCookieJar()
+		if err != nil {
+			return err
+		}
+		if args.LoadCookies != "" {
+			if err := jar.load(args.LoadCookies); err != nil {
+				return err
+			}
+		}
+		args.jar = jar
+		if args.SaveCookies != "" {
+			defer func() {
+				if err := jar.save(args.SaveCookies, args.KeepSessionCookies); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+				}
+			}()
+		}
+	}
+	tlsConfig, err := args.newTLSConfig()
+	if err != nil {
+		return err
+	}
+	args.tlsConfig = tlsConfig
+	args.Client = args.newHTTPClient()
+
 	// Setup logging for background mode
 	var logger *log.Logger
 	var logFile *os.File
 
 	if args.Background {
+		// The log detach created, stdout and stderr already point there too
 		var err error
-		logFile, err = Create_Output_file(false, "wget-log")
+		logFile, err = os.OpenFile(os.Getenv(backgroundLogEnv), os.O_WRONLY|os.O_APPEND, 0)
 		if err != nil {
-			return fmt.Errorf("failed to create log file: %v", err)
+			return fmt.Errorf("failed to open log file: %v", err)
 		}
 		defer logFile.Close()
 
 		logger = log.New(logFile, "", 0)
-		fmt.Printf(`Output will be written to '%s'.`,logFile.Name())
 
 		// Log start time
 		logger.Printf("start at %s", time.Now().Format("2006-01-02 15:04:05"))
 	}
+	args.logger = logger
+	ctx, stop := args.handleSignals(args.ctx)
+	defer stop()
+	args.ctx = ctx
 
 	// Choose execution path based on flags
 	if args.InputFile != "" {
 		// Batch download from file
-		// return args.executeBatchDownload(logger)
+		entries, err := readBatch(args.InputFile)
+		if err != nil {
+			return err
+		}
+		if err := args.setBatch(entries); err != nil {
+			return err
+		}
+		return args.downloadBatch(entries, logger)
 	} else if args.isMirror {
-		fmt.Println("++++++++++++++++++++++++++++++",args)
+		stopDashboard := args.startDashboard()
+		defer stopDashboard()
 		for _, link := range args.Links {
 
 			args.NewMirrorConfig(link)
@@ -43,14 +124,29 @@ func DownloadFiles(args *FlagsComponents) error {
 			if !args.Background {
 				logStart(link)
 			}
-			if err := args.ParseAndDownload(link); err != nil {
-				logError(err.Error())
-				os.Exit(1)
+			// Returned rather than exiting, so the report, the cookies, the
+			// pid file and the terminal are all seen to on the way out
+			if err := args.ParseAndDownload(link); err != nil && !args.interrupted() {
+				return err
 			}
+			root, _ := url.Parse(link)
+			if args.interrupted() {
+				if err := args.saveMirrorState(root); err != nil {
+					fmt.Fprintln(Stderr, err)
+				}
+				break
+			}
+			args.removeMirrorState(root)
 			if !args.Background {
 				logFinish(link)
 			}
 		}
+		if args.interrupted() {
+			return fmt.Errorf("mirror %w, the pages saved so far are kept, run again with -c to resume", errInterrupted)
+		}
+		if err := args.quotaError(); err != nil {
+			return err
+		}
 		// return nil
 	} else {
 		// Single file download
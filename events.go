@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// event is one line of the --events stream, and one entry of the --report
+// results for saved and error events (checked for --spider)
+type event struct {
+	Time     time.Time `json:"time"`
+	Event    string    `json:"event"` // start, response, progress, redirect, retry, saved or error
+	URL      string    `json:"url,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Status   int       `json:"status,omitempty"`
+	Bytes    int64     `json:"bytes,omitempty"`
+	Total    int64     `json:"total,omitempty"`
+	Location string    `json:"location,omitempty"`
+	Attempt  int       `json:"attempt,omitempty"`
+	Wait     float64   `json:"wait_seconds,omitempty"`
+	Elapsed  float64   `json:"elapsed_seconds,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// eventLog writes the event stream and keeps the outcomes for the report
+type eventLog struct {
+	mu      sync.Mutex
+	out     *os.File
+	enc     *json.Encoder
+	results []event
+	started time.Time
+}
+
+// parseEventsFD reads --events-fd, --events=json is the same on stdout
+func parseEventsFD(value string) (int, error) {
+	fd, err := strconv.Atoi(value)
+	if err != nil || fd < 1 {
+		return 0, fmt.Errorf("invalid file descriptor for --events-fd: %s", value)
+	}
+	return fd, nil
+}
+
+// openEvents sets up the event stream and the report, whichever was asked for
+func (c *FlagsComponents) openEvents() error {
+	c.events = &eventLog{started: time.Now()}
+	if c.EventsFD == 0 {
+		return nil
+	}
+	f := os.NewFile(uintptr(c.EventsFD), "events")
+	if f == nil {
+		return fmt.Errorf("--events-fd=%d is not open", c.EventsFD)
+	}
+	if _, err := f.Stat(); err != nil {
+		return fmt.Errorf("--events-fd=%d is not open: %v", c.EventsFD, err)
+	}
+	c.events.out, c.events.enc = f, json.NewEncoder(f)
+	return nil
+}
+
+// eventsOnTerminal is an event stream going to a terminal, where a bar
+// redrawn in place would end up mixed with the JSON lines
+func (c *FlagsComponents) eventsOnTerminal() bool {
+	return c.events != nil && c.events.out != nil && isTerminal(c.events.out)
+}
+
+// emit sends e down the event stream, saved and error events also go into
+// the report
+func (c *FlagsComponents) emit(e event) {
+	if c.events == nil {
+		return
+	}
+	e.Time = time.Now()
+	if e.Total < 0 {
+		// Unknown, left out like every other missing field
+		e.Total = 0
+	}
+	c.events.mu.Lock()
+	defer c.events.mu.Unlock()
+	if c.events.enc != nil {
+		c.events.enc.Encode(e)
+	}
+	if e.Event == "saved" || e.Event == "error" {
+		c.events.results = append(c.events.results, e)
+	}
+}
+
+// record puts an outcome into the report without streaming it, for
+// --spider where nothing is saved
+func (c *FlagsComponents) record(e event) {
+	if c.events == nil {
+		return
+	}
+	e.Time = time.Now()
+	c.events.mu.Lock()
+	c.events.results = append(c.events.results, e)
+	c.events.mu.Unlock()
+}
+
+// report is the --report file written at the end of the run
+type report struct {
+	Started      time.Time `json:"started"`
+	Finished     time.Time `json:"finished"`
+	Elapsed      float64   `json:"elapsed_seconds"`
+	Succeeded    int       `json:"succeeded"`
+	Failed       int       `json:"failed"`
+	Bytes        int64     `json:"bytes_retrieved"`
+	QuotaSkipped int64     `json:"quota_skipped,omitempty"`
+	Interrupted  bool      `json:"interrupted,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	Results      []event   `json:"results"`
+}
+
+// writeReport summarises the run into --report, runErr is how it ended
+func (c *FlagsComponents) writeReport(runErr error) error {
+	c.events.mu.Lock()
+	defer c.events.mu.Unlock()
+	r := report{
+		Started:      c.events.started,
+		Finished:     time.Now(),
+		Bytes:        c.retrieved.Load(),
+		QuotaSkipped: c.quotaSkipped.Load(),
+		Interrupted:  c.interrupted(),
+		Results:      c.events.results,
+	}
+	r.Elapsed = r.Finished.Sub(r.Started).Seconds()
+	if r.Results == nil {
+		r.Results = []event{}
+	}
+	for _, e := range r.Results {
+		if e.Event == "error" {
+			r.Failed++
+		} else {
+			r.Succeeded++
+		}
+	}
+	if runErr != nil {
+		r.Error = runErr.Error()
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(c.Report, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write report: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseEventsFD(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+		ok   bool
+	}{
+		{"1", 1, true},
+		{"3", 3, true},
+		{"0", 0, false},
+		{"-1", 0, false},
+		{"three", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseEventsFD(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseEventsFD(%q) = %d, %v, want %d, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestEmitAndReport(t *testing.T) {
+	var stream bytes.Buffer
+	c := &FlagsComponents{Report: filepath.Join(t.TempDir(), "report.json")}
+	c.events = &eventLog{enc: json.NewEncoder(&stream)}
+
+	c.emit(event{Event: "start", URL: "http://example.com/a"})
+	c.emit(event{Event: "response", URL: "http://example.com/a", Status: 200, Total: -1})
+	c.emit(event{Event: "saved", URL: "http://example.com/a", Path: "a", Bytes: 10})
+	c.emit(event{Event: "error", URL: "http://example.com/b", Error: "404 Not Found"})
+	c.record(event{Event: "checked", URL: "http://example.com/c"})
+
+	lines := strings.Split(strings.TrimSpace(stream.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d event lines, want 4:\n%s", len(lines), stream.String())
+	}
+	if strings.Contains(lines[1], `"total"`) {
+		t.Errorf("unknown length was streamed: %s", lines[1])
+	}
+
+	if err := c.writeReport(nil); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(c.Report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r report
+	if err := json.Unmarshal(data, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Succeeded != 2 || r.Failed != 1 || len(r.Results) != 3 {
+		t.Errorf("report counts %d succeeded, %d failed, %d results, want 2, 1, 3", r.Succeeded, r.Failed, len(r.Results))
+	}
+}
@@ -5,6 +5,12 @@ go 1.23.0
 toolchain go1.23.11
 
 require (
-	golang.org/x/net v0.42.0 // indirect
+	golang.org/x/net v0.42.0
+	golang.org/x/term v0.33.0
+)
+
+require (
 	golang.org/x/sync v0.16.0 // indirect
+	golang.org/x/sys v0.34.0 // indirect
+	golang.org/x/text v0.27.0 // indirect
 )
@@ -26,6 +26,6 @@ func main() {
 	err = DownloadFiles(&components)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		return
+		os.Exit(1)
 	}
 }
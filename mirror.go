@@ -3,8 +3,10 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"fmt"
-	"io/ioutil"
+	"log"
 	"net/http"
 	"net/url"
 	"os"
@@ -12,6 +14,7 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/net/html"
@@ -35,7 +38,132 @@ type FlagsComponents struct {
 	MaxDepth     int
 	visited      map[string]struct{}
 	visitedMu    sync.RWMutex
+	saved        map[string]*mirrorPage // see mirrorState
+	resumed      map[string]*mirrorPage
+	savedMu      sync.Mutex
 	// wg         sync.WaitGroup
+
+	// Resuming, splitting and naming single downloads
+	Continue           bool
+	Segments           int
+	ContentDisposition bool
+	Timestamping       bool
+	KeepPartial        bool
+
+	// Checksum verification, see checksum.go
+	Checksum        *checksum
+	ChecksumURL     string
+	ChecksumAuto    bool
+	KeepBadChecksum bool
+
+	// Retry policy
+	Tries            int
+	WaitRetry        time.Duration
+	RetryConnRefused bool
+	RetryOnHTTPError []int
+
+	// Timeouts, zero means no limit
+	DNSTimeout     time.Duration
+	ConnectTimeout time.Duration
+	ReadTimeout    time.Duration
+	Deadline       time.Duration
+	LowSpeedLimit  int64
+	LowSpeedTime   time.Duration
+	ctx            context.Context
+
+	// Request customisation, see request.go
+	Headers   http.Header
+	UserAgent *string
+	Referer   string
+	Method    string
+	PostData  string
+	PostFile  string
+	BodyData  string
+
+	// Authentication, see auth.go
+	User            string
+	Password        string
+	HTTPUser        string
+	HTTPPassword    string
+	AskPassword     bool
+	AuthNoChallenge bool
+	netrc           map[string]*credentials
+	authOrigins     map[string]bool
+	authOnce        sync.Once
+
+	// Cookies, see cookies.go
+	LoadCookies        string
+	SaveCookies        string
+	KeepSessionCookies bool
+	NoCookies          bool
+	jar                *cookieJar
+
+	// Proxies, see proxy.go
+	Proxy         string
+	NoProxy       bool
+	ProxyUser     string
+	ProxyPassword string
+	proxyFunc     func(*url.URL) (*url.URL, error)
+	proxyOnce     sync.Once
+
+	// TLS, see tls.go
+	CACertificate      string
+	CADirectory        string
+	Certificate        string
+	PrivateKey         string
+	PinnedPubKey       [][]byte
+	SecureProtocol     uint16
+	NoCheckCertificate bool
+	tlsConfig          *tls.Config
+
+	// Batch downloads, see batch.go
+	Jobs         int
+	batchOptions map[string]*batchEntry
+
+	// Check links without saving, see spider.go
+	Spider bool
+
+	// Header output, see debug.go
+	ServerResponse bool
+	SaveHeaders    bool
+	Debug          bool
+	logger         *log.Logger
+
+	// Download quota, see quota.go
+	Quota        int64
+	retrieved    atomic.Int64
+	quotaSkipped atomic.Int64
+
+	// Redirect policy, see redirect.go
+	MaxRedirect      *int
+	AllowDowngrade   bool
+	TrustServerNames bool
+
+	// Compression, see compression.go
+	Compression         string
+	MaxDecompressedSize int64
+	KeepEncoded         bool
+
+	// Progress display, see progress.go
+	Progress     string
+	DotStyle     string
+	ShowProgress bool
+
+	// Background mode, see background.go
+	PIDFile string
+
+	// Live progress of concurrent transfers, see dashboard.go
+	dash *dashboard
+
+	// Event stream and end-of-run report, see events.go
+	EventsFD int
+	Report   string
+	events   *eventLog
+
+	// Signals and status snapshots, see signals.go
+	transfers   map[*transfer]struct{}
+	transfersMu sync.Mutex
+	pending     atomic.Int64
 }
 
 var cssURLRegex = regexp.MustCompile(`url\(['"]?([^'")]+)['"]?\)`)
@@ -48,42 +176,20 @@ func (m *FlagsComponents) NewMirrorConfig(rootURL string) error {
 	}
 	host := u.Host
 
-	// Create transport with idle connections
-	transport := &http.Transport{
-		MaxIdleConns:       10,
-		IdleConnTimeout:    30 * time.Second,
-		DisableCompression: false,
-	}
-
-	client := &http.Client{
-		Timeout:   30 * time.Second,
-		Transport: transport,
-	}
-
 	m.BaseDir = "."
 	m.MaxDepth = 3
-	m.Background = false
 	m.OnlySameHost = true
 	m.RootHost = host
-	m.Client = client
+	m.Client = m.newHTTPClient()
 	m.visited = make(map[string]struct{})
 	m.visitedMu = sync.RWMutex{}
+	m.loadMirrorState(u)
 
 	return nil
 }
 
 // ParseAndDownload downloads a page and its assets
 func (m *FlagsComponents) ParseAndDownload(pageURL string) error {
-	// Log background mode if enabled
-	if m.Background {
-		logBackground()
-		// Optionally redirect output to log file
-		if file, err := openLogFile(); err == nil {
-			Stdout = file
-			Stderr = file
-		}
-	}
-
 	logStart(pageURL)
 
 	u, err := url.Parse(pageURL)
@@ -101,6 +207,13 @@ func (m *FlagsComponents) ParseAndDownload(pageURL string) error {
 }
 
 func (m *FlagsComponents) crawl(u *url.URL, depth int) error {
+	// Linked pages count as queued until their response arrives
+	dequeue := func() {}
+	if depth > 0 {
+		var once sync.Once
+		dequeue = func() { once.Do(func() { m.pending.Add(-1) }) }
+		defer dequeue()
+	}
 	absURL := u.String()
 	// Skip if already visited
 	m.visitedMu.Lock()
@@ -114,7 +227,7 @@ func (m *FlagsComponents) crawl(u *url.URL, depth int) error {
 	// Check reject list before downloading
 	for _, ext := range m.Reject {
 		if strings.HasSuffix(strings.ToLower(u.Path), strings.ToLower(ext)) {
-			fmt.Printf("[INFO] Skipping %s due to reject rule (%s)\n", u.String(), ext)
+			fmt.Fprintf(Stdout, "[INFO] Skipping %s due to reject rule (%s)\n", u.String(), ext)
 			return nil
 		}
 	}
@@ -122,7 +235,7 @@ func (m *FlagsComponents) crawl(u *url.URL, depth int) error {
 	// Skip URLs with excluded path prefixes
 	for _, prefix := range m.Exclude {
 		if strings.HasPrefix(u.Path, prefix) {
-			fmt.Printf("[INFO] Skipping %s due to exclude path prefix (%s)\n", u.String(), prefix)
+			fmt.Fprintf(Stdout, "[INFO] Skipping %s due to exclude path prefix (%s)\n", u.String(), prefix)
 			return nil
 		}
 	}
@@ -137,23 +250,88 @@ func (m *FlagsComponents) crawl(u *url.URL, depth int) error {
 		return nil
 	}
 
-	req, err := http.NewRequest("GET", u.String(), nil)
-	if err != nil {
-		return err
+	// Saved before mirror -c, only its links are followed again
+	if page := m.resumedPage(absURL); page != nil {
+		dequeue()
+		fmt.Fprintf(Stdout, "[INFO] Already saved %s as %s\n", absURL, page.Path)
+		m.emit(event{Event: "saved", URL: absURL, Path: page.Path, Reason: "already saved"})
+		m.savedPage(absURL, page)
+		m.crawlLinks(u, page.Links, depth)
+		return nil
 	}
 
-	// Set a real User-Agent
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; Wget/1.21)")
-	resp, err := m.Client.Do(req)
-	if err != nil {
-		logError(fmt.Sprintf("Failed to fetch %s: %v", u.String(), err))
+	// Past -Q or after Ctrl-C nothing new is fetched
+	if m.skipForQuota() || m.interrupted() {
+		return nil
+	}
+
+	// Fetch the whole body, retrying transient failures per --tries
+	var resp *http.Response
+	var body []byte
+	say := func(msg string) { fmt.Fprint(Stdout, msg) }
+	fetched := time.Now()
+	fail := func(err error) error {
+		if !m.interrupted() {
+			m.emit(event{Event: "error", URL: u.String(), Error: err.Error()})
+		}
 		return err
 	}
-	defer resp.Body.Close()
+	err := m.retry(u.String(), say, func() error {
+		// Only the start page gets the user's method and body, assets are plain GETs
+		method := "GET"
+		if depth == 0 {
+			method = ""
+		}
+		req, err := m.newRequest(method, u.String())
+		if err != nil {
+			return err
+		}
+		m.acceptEncoding(req)
+		m.emit(event{Event: "start", URL: u.String()})
+		resp, err = m.Client.Do(req)
+		if err != nil {
+			if !m.interrupted() {
+				logError(fmt.Sprintf("Failed to fetch %s: %v", u.String(), err))
+			}
+			return err
+		}
+		m.emit(event{Event: "response", URL: u.String(), Status: resp.StatusCode, Total: resp.ContentLength})
+		dequeue()
+		resp.Body = m.track(u.String(), resp.ContentLength, m.countRetrieved(m.watchSpeed(resp.Body)))
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			logError(fmt.Sprintf("HTTP %d: %s", resp.StatusCode, resp.Status))
+			return newHTTPStatusError(resp)
+		}
 
-	if resp.StatusCode != http.StatusOK {
-		logError(fmt.Sprintf("HTTP %d: %s", resp.StatusCode, resp.Status))
-		return fmt.Errorf("failed: %s", resp.Status)
+		decoded, err := m.decodeBody(resp)
+		if err != nil {
+			logError(fmt.Sprintf("Failed to read body from %s: %v", u.String(), err))
+			return err
+		}
+		var buf bytes.Buffer
+		_, err = copyWithProgress(resp.Body, &buf, m.pageProgress(u.String(), resp.ContentLength))
+		body = buf.Bytes()
+		if err != nil {
+			if !m.interrupted() {
+				logError(fmt.Sprintf("Failed to read body from %s: %v", u.String(), err))
+			}
+			return err
+		}
+		received := int64(len(body))
+		if decoded != nil {
+			received = decoded.encoded
+			say(decoded.String())
+		}
+		if resp.ContentLength > 0 && received < resp.ContentLength {
+			logError(fmt.Sprintf("Truncated body from %s", u.String()))
+			return &truncatedError{got: received, want: resp.ContentLength}
+		}
+		return nil
+	})
+	if err != nil {
+		return fail(err)
 	}
 
 	// Log the request status
@@ -163,19 +341,13 @@ func (m *FlagsComponents) crawl(u *url.URL, depth int) error {
 	localPath, err := m.GetLocalPath(u, contentType)
 	if err != nil {
 		logError(fmt.Sprintf("Failed to determine path for %s: %v", u.String(), err))
-		return err
+		return fail(err)
 	}
 
 	// Create dir and save file
 	if err := os.MkdirAll(filepath.Dir(localPath), 0o755); err != nil {
 		logError(fmt.Sprintf("Failed to create directory for %s: %v", localPath, err))
-		return err
-	}
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		logError(fmt.Sprintf("Failed to read body from %s: %v", u.String(), err))
-		return err
+		return fail(err)
 	}
 
 	// Log file size and saving path
@@ -183,10 +355,11 @@ func (m *FlagsComponents) crawl(u *url.URL, depth int) error {
 	logSize(size)
 	logSaving(localPath)
 
-	if err := ioutil.WriteFile(localPath, body, 0o644); err != nil {
+	if err := writeFileAtomic(localPath, body); err != nil {
 		logError(fmt.Sprintf("Failed to write file %s: %v", localPath, err))
-		return err
+		return fail(err)
 	}
+	m.emit(event{Event: "saved", URL: u.String(), Path: localPath, Status: resp.StatusCode, Bytes: size, Elapsed: time.Since(fetched).Seconds()})
 
 	// === NEW: convert links inside saved HTML if --convert-links is enabled ===
 	if m.Convert && strings.Contains(contentType, "text/html") {
@@ -194,7 +367,7 @@ func (m *FlagsComponents) crawl(u *url.URL, depth int) error {
 		if err != nil {
 			logError(fmt.Sprintf("Failed to convert links in %s: %v", localPath, err))
 		} else {
-			err = ioutil.WriteFile(localPath, convertedBody, 0o644)
+			err = writeFileAtomic(localPath, convertedBody)
 			if err != nil {
 				logError(fmt.Sprintf("Failed to write converted file %s: %v", localPath, err))
 			}
@@ -202,6 +375,7 @@ func (m *FlagsComponents) crawl(u *url.URL, depth int) error {
 	}
 
 	// Extract links if HTML
+	var links []string
 	if strings.Contains(contentType, "text/html") {
 		doc, err := html.Parse(strings.NewReader(string(body)))
 		if err != nil {
@@ -260,43 +434,40 @@ func (m *FlagsComponents) crawl(u *url.URL, depth int) error {
 			}
 		}
 		extract(doc)
-
-		// Download found links
-		var wg sync.WaitGroup
 		for link := range seen {
-			linkURL, err := u.Parse(link)
-			if err != nil {
-				continue
-			}
-			wg.Add(1)
-			go func(url *url.URL) {
-				defer wg.Done()
-				_ = m.crawl(url, depth+1)
-			}(linkURL)
+			links = append(links, link)
 		}
-		wg.Wait()
 	}
 
 	// Parse CSS files
 	if strings.Contains(contentType, "css") {
-		links := m.extractCSSLinks(body, u)
-		var wg sync.WaitGroup
-		for _, link := range links {
-			linkURL, err := u.Parse(link)
-			if err != nil {
-				continue
-			}
-			wg.Add(1)
-			go func(url *url.URL) {
-				defer wg.Done()
-				_ = m.crawl(url, depth+1)
-			}(linkURL)
-		}
-		wg.Wait()
+		links = append(links, m.extractCSSLinks(body, u)...)
 	}
+
+	// Download found links, after noting them down for mirror -c
+	m.savedPage(absURL, &mirrorPage{Path: localPath, Links: links})
+	m.crawlLinks(u, links, depth)
 	return nil
 }
 
+// crawlLinks fetches the links found on the page at u, all at once
+func (m *FlagsComponents) crawlLinks(u *url.URL, links []string, depth int) {
+	var wg sync.WaitGroup
+	for _, link := range links {
+		linkURL, err := u.Parse(link)
+		if err != nil {
+			continue
+		}
+		wg.Add(1)
+		m.pending.Add(1)
+		go func(url *url.URL) {
+			defer wg.Done()
+			_ = m.crawl(url, depth+1)
+		}(linkURL)
+	}
+	wg.Wait()
+}
+
 // extractURLs handles normal or srcset URLs
 func (m *FlagsComponents) extractURLs(val string, base *url.URL, seen map[string]struct{}, isSrcSet bool) {
 	if isSrcSet {
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// toStdout is -O -, the body goes to stdout and the chatter to stderr
+func (c *FlagsComponents) toStdout() bool {
+	return c.OutputFile == "-"
+}
+
+// concatenating is one -O file for several URLs, appended in order like GNU wget
+func (c *FlagsComponents) concatenating() bool {
+	return c.OutputFile != "" && c.OutputFile != "-" && len(c.Links) > 1
+}
+
+// sharedOutput is an output other downloads write to as well, so it is
+// never renamed, removed, resumed or stamped with the server's mtime
+func (c *FlagsComponents) sharedOutput() bool {
+	return c.toStdout() || c.concatenating()
+}
+
+// openOutput opens the destination of one download and returns the name
+// it will end up under. Files of their own are written to a .part file
+func (c *FlagsComponents) openOutput(filename string, Overide, resume bool, offset int64) (*os.File, string, error) {
+	switch {
+	case c.toStdout():
+		return os.Stdout, filename, nil
+	case c.concatenating():
+		out, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		return out, filename, err
+	case resume && offset > 0:
+		out, err := openForResume(resumeTarget(filename), offset)
+		return out, filename, err
+	case resume:
+		// Starting over goes to the .part file, a file under the final
+		// name stays as it is until the new one is complete
+		out, err := openForResume(partName(filename), 0)
+		return out, filename, err
+	default:
+		// -N refreshes the file in place instead of adding file.1
+		return createPart(filename, Overide || c.Timestamping)
+	}
+}
+
+// partName is where a download is written until it is complete, so nothing
+// ever finds a half written file under the final name
+func partName(filename string) string {
+	return filename + ".part"
+}
+
+// claimedNames are the names createPart handed out in this run. A name
+// stays free on disk until its download completes, so without them two -j
+// workers saving under the same name would both pick it and share a .part
+var (
+	claimedMu    sync.Mutex
+	claimedNames = make(map[string]bool)
+)
+
+// nameFree is a name no file has, no other download is writing to and no
+// download of this run has claimed, claimedMu is held by the caller
+func nameFree(filename string) bool {
+	for _, name := range []string{filename, partName(filename)} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			return false
+		}
+	}
+	return !claimedNames[filename]
+}
+
+// createPart picks the final name of a new download, file.1 style unless
+// it may replace an existing file, and creates the .part file for it
+func createPart(filename string, Overide bool) (*os.File, string, error) {
+	if Overide {
+		out, err := os.Create(partName(filename))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create file: %v", err)
+		}
+		return out, filename, nil
+	}
+	claimedMu.Lock()
+	defer claimedMu.Unlock()
+	for {
+		name := freeName(filename)
+		// O_EXCL also keeps another wget in the same directory off the
+		// name, if it got there first we move on to the next one
+		out, err := os.OpenFile(partName(name), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create file: %v", err)
+		}
+		claimedNames[name] = true
+		return out, name, nil
+	}
+}
+
+// commitPart flushes a finished download to disk and moves it into place
+func commitPart(out *os.File, filename string) error {
+	if err := out.Sync(); err != nil {
+		return fmt.Errorf("failed to write '%s': %v", filename, err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write '%s': %v", filename, err)
+	}
+	// Resumed in place with -c, it is there already
+	if out.Name() == filename {
+		return nil
+	}
+	if err := os.Rename(partName(filename), filename); err != nil {
+		return fmt.Errorf("failed to move '%s' into place: %v", filename, err)
+	}
+	return nil
+}
+
+// writeFileAtomic is os.WriteFile through a .part file, for mirror pages
+func writeFileAtomic(filename string, data []byte) error {
+	out, err := os.Create(partName(filename))
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(data); err != nil {
+		out.Close()
+		os.Remove(partName(filename))
+		return err
+	}
+	if err := commitPart(out, filename); err != nil {
+		os.Remove(partName(filename))
+		return err
+	}
+	return nil
+}
+
+// keepPartial is --keep-partial, and -c which resumes partial files anyway
+func (c *FlagsComponents) keepPartial() bool {
+	return c.KeepPartial || c.Continue
+}
+
+// dropPartial cleans up after a download that failed for good
+func (c *FlagsComponents) dropPartial(filename string, say func(string)) {
+	if c.keepPartial() || c.sharedOutput() {
+		return
+	}
+	if err := os.Remove(partName(filename)); err == nil {
+		say(fmt.Sprintf("Removed the partial download '%s', use --keep-partial to keep it.\n", partName(filename)))
+	}
+	removeResumeState(filename)
+}
+
+// streamFailed ends the retries of a download that already wrote part of
+// its body to stdout, another attempt would write those bytes twice
+func (c *FlagsComponents) streamFailed(err error, written int64) error {
+	if c.toStdout() && written > 0 {
+		return fmt.Errorf("%v, not retrying after %d bytes went to stdout", err, written)
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestFreeName(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "a.1.txt", "noext", "c.txt.part", "c.1.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := map[string]string{
+		"a.txt":   "a.2.txt",
+		"b.txt":   "b.txt",
+		"noext":   "noext.1",
+		"a.1.txt": "a.1.1.txt",
+		"c.txt":   "c.2.txt",
+	}
+	for in, want := range tests {
+		claimedMu.Lock()
+		got := freeName(filepath.Join(dir, in))
+		claimedMu.Unlock()
+		if got != filepath.Join(dir, want) {
+			t.Errorf("freeName(%s) = %s, want %s", in, filepath.Base(got), want)
+		}
+	}
+}
+
+func TestCreatePartKeepsOtherRunsPart(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "file.bin")
+	// Another wget is half way through file.bin
+	if err := os.WriteFile(partName(filename), []byte("in progress"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, name, err := createPart(filename, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Close()
+	if want := filepath.Join(dir, "file.1.bin"); name != want {
+		t.Errorf("createPart picked %s, want %s", filepath.Base(name), filepath.Base(want))
+	}
+	if data, _ := os.ReadFile(partName(filename)); string(data) != "in progress" {
+		t.Errorf("the other run's .part was changed to %q", data)
+	}
+}
+
+func TestCreatePartClaimsNames(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "index.html")
+	const workers = 8
+	names := make(chan string, workers)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out, name, err := createPart(filename, false)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			out.Close()
+			names <- name
+		}()
+	}
+	wg.Wait()
+	close(names)
+
+	seen := make(map[string]bool)
+	for name := range names {
+		if seen[name] {
+			t.Errorf("%s was handed out twice", filepath.Base(name))
+		}
+		seen[name] = true
+		if _, err := os.Stat(partName(name)); err != nil {
+			t.Errorf("no .part file for %s: %v", filepath.Base(name), err)
+		}
+	}
+	if len(seen) != workers {
+		t.Errorf("got %d names for %d downloads", len(seen), workers)
+	}
+}
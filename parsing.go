@@ -3,11 +3,28 @@ package main
 import (
 	"errors"
 	"fmt"
+	"net/http"
+	"strconv"
 	"strings"
 )
 
 func parsing(args []string, components *FlagsComponents) error {
-	flags := []string{"-O", "-B", "-P", "--limit-rate", "--mirror", "-R", "--reject", "-X", "--exclude", "--convert-links", "-i"}
+	flags := []string{"-O", "-B", "-P", "--limit-rate", "--mirror", "-R", "--reject", "-X", "--exclude", "--convert-links", "-i", "-c", "--continue", "--segments",
+		"--tries", "--waitretry", "--retry-connrefused", "--retry-on-http-error",
+		"-T", "--timeout", "--dns-timeout", "--connect-timeout", "--read-timeout", "--deadline",
+		"--low-speed-limit", "--low-speed-time",
+		"--header", "-U", "--user-agent", "--referer", "--method", "--post-data", "--post-file", "--body-data",
+		"--user", "--password", "--http-user", "--http-password", "--ask-password", "--auth-no-challenge",
+		"--load-cookies", "--save-cookies", "--keep-session-cookies", "--no-cookies",
+		"--content-disposition", "-N", "--timestamping",
+		"--checksum", "--checksum-url", "--keep-bad-checksum",
+		"--proxy", "--no-proxy", "--proxy-user", "--proxy-password",
+		"--ca-certificate", "--ca-directory", "--certificate", "--private-key",
+		"--pinnedpubkey", "--secure-protocol", "--no-check-certificate", "-j", "--jobs", "--spider",
+		"-S", "--server-response", "--save-headers", "-d", "--debug", "-Q", "--quota",
+		"--max-redirect", "--allow-downgrade", "--trust-server-names",
+		"--compression", "--max-decompressed-size", "--keep-encoded", "--keep-partial", "--pid-file",
+		"--progress", "--show-progress", "--events", "--events-fd", "--report"}
 
 	i := 0
 	for i < len(args) {
@@ -75,6 +92,443 @@ func parsing(args []string, components *FlagsComponents) error {
 				return errors.New("invalid flag --B")
 			}
 			components.Background = true
+		} else if strings.HasPrefix(args[i], "--segments") {
+			value, next, err := CatchValue(args[i:], flags)
+			if err != nil {
+				return err
+			}
+			components.Segments, err = strconv.Atoi(value)
+			if err != nil || components.Segments < 1 {
+				return fmt.Errorf("invalid number of segments: %s", value)
+			}
+			if next {
+				i += 2
+				continue
+			}
+		} else if strings.HasPrefix(args[i], "--tries") {
+			value, next, err := CatchValue(args[i:], flags)
+			if err != nil {
+				return err
+			}
+			components.Tries, err = strconv.Atoi(value)
+			if err != nil || components.Tries < 1 {
+				return fmt.Errorf("invalid number of tries: %s", value)
+			}
+			if next {
+				i += 2
+				continue
+			}
+		} else if strings.HasPrefix(args[i], "--waitretry") {
+			value, next, err := CatchValue(args[i:], flags)
+			if err != nil {
+				return err
+			}
+			components.WaitRetry, err = parseSeconds(value)
+			if err != nil {
+				return err
+			}
+			if next {
+				i += 2
+				continue
+			}
+		} else if strings.HasPrefix(args[i], "--retry-connrefused") {
+			if !CheckValidFlag(args[i], flags) {
+				return fmt.Errorf("invalid flag %s", args[i])
+			}
+			components.RetryConnRefused = true
+		} else if strings.HasPrefix(args[i], "--retry-on-http-error") {
+			value, next, err := CatchValue(args[i:], flags)
+			if err != nil {
+				return err
+			}
+			components.RetryOnHTTPError, err = parseStatusList(value)
+			if err != nil {
+				return err
+			}
+			if next {
+				i += 2
+				continue
+			}
+		} else if name := flagName(args[i]); name == "-T" || strings.HasSuffix(name, "timeout") || name == "--deadline" || name == "--low-speed-time" {
+			value, next, err := CatchValue(args[i:], flags)
+			if err != nil {
+				return err
+			}
+			d, err := parseSeconds(value)
+			if err != nil {
+				return err
+			}
+			switch name {
+			case "-T", "--timeout":
+				// Shorthand for the three network timeouts at once
+				components.DNSTimeout, components.ConnectTimeout, components.ReadTimeout = d, d, d
+			case "--dns-timeout":
+				components.DNSTimeout = d
+			case "--connect-timeout":
+				components.ConnectTimeout = d
+			case "--read-timeout":
+				components.ReadTimeout = d
+			case "--deadline":
+				components.Deadline = d
+			case "--low-speed-time":
+				components.LowSpeedTime = d
+			}
+			if next {
+				i += 2
+				continue
+			}
+		} else if strings.HasPrefix(args[i], "--low-speed-limit") {
+			value, next, err := CatchValue(args[i:], flags)
+			if err != nil {
+				return err
+			}
+			components.LowSpeedLimit, err = parseRateLimit(value)
+			if err != nil {
+				return err
+			}
+			if next {
+				i += 2
+				continue
+			}
+		} else if args[i] == "--user-agent=" {
+			// Explicitly empty, send no User-Agent header
+			empty := ""
+			components.UserAgent = &empty
+		} else if name := flagName(args[i]); name == "--header" || name == "-U" || name == "--user-agent" || name == "--referer" ||
+			name == "--method" || name == "--post-data" || name == "--post-file" || name == "--body-data" {
+			value, next, err := CatchValue(args[i:], flags)
+			if err != nil {
+				return err
+			}
+			switch name {
+			case "--header":
+				if components.Headers == nil {
+					components.Headers = http.Header{}
+				}
+				if err := parseHeader(value, components.Headers); err != nil {
+					return err
+				}
+			case "-U", "--user-agent":
+				components.UserAgent = &value
+			case "--referer":
+				components.Referer = value
+			case "--method":
+				components.Method = strings.ToUpper(value)
+			case "--post-data":
+				components.PostData = value
+			case "--post-file":
+				components.PostFile = value
+			case "--body-data":
+				components.BodyData = value
+			}
+			if next {
+				i += 2
+				continue
+			}
+		} else if name := flagName(args[i]); name == "--user" || name == "--password" || name == "--http-user" || name == "--http-password" {
+			value, next, err := CatchValue(args[i:], flags)
+			if err != nil {
+				return err
+			}
+			switch name {
+			case "--user":
+				components.User = value
+			case "--password":
+				components.Password = value
+			case "--http-user":
+				components.HTTPUser = value
+			case "--http-password":
+				components.HTTPPassword = value
+			}
+			if next {
+				i += 2
+				continue
+			}
+		} else if strings.HasPrefix(args[i], "--ask-password") {
+			if !CheckValidFlag(args[i], flags) {
+				return fmt.Errorf("invalid flag %s", args[i])
+			}
+			components.AskPassword = true
+		} else if strings.HasPrefix(args[i], "--auth-no-challenge") {
+			if !CheckValidFlag(args[i], flags) {
+				return fmt.Errorf("invalid flag %s", args[i])
+			}
+			components.AuthNoChallenge = true
+		} else if name := flagName(args[i]); name == "--load-cookies" || name == "--save-cookies" {
+			value, next, err := CatchValue(args[i:], flags)
+			if err != nil {
+				return err
+			}
+			if name == "--load-cookies" {
+				components.LoadCookies = value
+			} else {
+				components.SaveCookies = value
+			}
+			if next {
+				i += 2
+				continue
+			}
+		} else if strings.HasPrefix(args[i], "--keep-session-cookies") {
+			if !CheckValidFlag(args[i], flags) {
+				return fmt.Errorf("invalid flag %s", args[i])
+			}
+			components.KeepSessionCookies = true
+		} else if strings.HasPrefix(args[i], "--no-cookies") {
+			if !CheckValidFlag(args[i], flags) {
+				return fmt.Errorf("invalid flag %s", args[i])
+			}
+			components.NoCookies = true
+		} else if strings.HasPrefix(args[i], "--content-disposition") {
+			if !CheckValidFlag(args[i], flags) {
+				return fmt.Errorf("invalid flag %s", args[i])
+			}
+			components.ContentDisposition = true
+		} else if strings.HasPrefix(args[i], "-N") || strings.HasPrefix(args[i], "--timestamping") {
+			if !CheckValidFlag(args[i], flags) {
+				return fmt.Errorf("invalid flag %s", args[i])
+			}
+			components.Timestamping = true
+		} else if name := flagName(args[i]); name == "--checksum" || name == "--checksum-url" {
+			value, next, err := CatchValue(args[i:], flags)
+			if err != nil {
+				return err
+			}
+			switch {
+			case name == "--checksum-url":
+				components.ChecksumURL = value
+			case value == "auto":
+				components.ChecksumAuto = true
+			default:
+				if components.Checksum, err = parseChecksum(value); err != nil {
+					return err
+				}
+			}
+			if next {
+				i += 2
+				continue
+			}
+		} else if strings.HasPrefix(args[i], "--keep-bad-checksum") {
+			if !CheckValidFlag(args[i], flags) {
+				return fmt.Errorf("invalid flag %s", args[i])
+			}
+			components.KeepBadChecksum = true
+		} else if name := flagName(args[i]); name == "--proxy" || name == "--proxy-user" || name == "--proxy-password" {
+			value, next, err := CatchValue(args[i:], flags)
+			if err != nil {
+				return err
+			}
+			switch name {
+			case "--proxy":
+				if components.Proxy, err = parseProxyURL(value); err != nil {
+					return err
+				}
+			case "--proxy-user":
+				components.ProxyUser = value
+			case "--proxy-password":
+				components.ProxyPassword = value
+			}
+			if next {
+				i += 2
+				continue
+			}
+		} else if strings.HasPrefix(args[i], "--no-proxy") {
+			if !CheckValidFlag(args[i], flags) {
+				return fmt.Errorf("invalid flag %s", args[i])
+			}
+			components.NoProxy = true
+		} else if name := flagName(args[i]); name == "--ca-certificate" || name == "--ca-directory" || name == "--certificate" ||
+			name == "--private-key" || name == "--pinnedpubkey" || name == "--secure-protocol" {
+			value, next, err := CatchValue(args[i:], flags)
+			if err != nil {
+				return err
+			}
+			switch name {
+			case "--ca-certificate":
+				components.CACertificate = value
+			case "--ca-directory":
+				components.CADirectory = value
+			case "--certificate":
+				components.Certificate = value
+			case "--private-key":
+				components.PrivateKey = value
+			case "--pinnedpubkey":
+				if components.PinnedPubKey, err = parsePinnedPubKey(value); err != nil {
+					return err
+				}
+			case "--secure-protocol":
+				if components.SecureProtocol, err = parseSecureProtocol(value); err != nil {
+					return err
+				}
+			}
+			if next {
+				i += 2
+				continue
+			}
+		} else if strings.HasPrefix(args[i], "--no-check-certificate") {
+			if !CheckValidFlag(args[i], flags) {
+				return fmt.Errorf("invalid flag %s", args[i])
+			}
+			components.NoCheckCertificate = true
+		} else if name := flagName(args[i]); name == "-j" || name == "--jobs" {
+			value, next, err := CatchValue(args[i:], flags)
+			if err != nil {
+				return err
+			}
+			jobs, err := strconv.Atoi(value)
+			if err != nil || jobs < 1 {
+				return fmt.Errorf("invalid number of jobs: %s", value)
+			}
+			components.Jobs = jobs
+			if next {
+				i += 2
+				continue
+			}
+		} else if strings.HasPrefix(args[i], "--spider") {
+			if !CheckValidFlag(args[i], flags) {
+				return fmt.Errorf("invalid flag %s", args[i])
+			}
+			components.Spider = true
+		} else if name := flagName(args[i]); name == "-S" || name == "--server-response" || name == "--save-headers" || name == "-d" || name == "--debug" {
+			if !CheckValidFlag(args[i], flags) {
+				return fmt.Errorf("invalid flag %s", args[i])
+			}
+			switch name {
+			case "-S", "--server-response":
+				components.ServerResponse = true
+			case "--save-headers":
+				components.SaveHeaders = true
+			case "-d", "--debug":
+				components.Debug = true
+			}
+		} else if name := flagName(args[i]); name == "-Q" || name == "--quota" {
+			value, next, err := CatchValue(args[i:], flags)
+			if err != nil {
+				return err
+			}
+			if components.Quota, err = parseSize(value); err != nil {
+				return err
+			}
+			if next {
+				i += 2
+				continue
+			}
+		} else if strings.HasPrefix(args[i], "--max-redirect") {
+			value, next, err := CatchValue(args[i:], flags)
+			if err != nil {
+				return err
+			}
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 0 {
+				return fmt.Errorf("invalid --max-redirect: %s", value)
+			}
+			components.MaxRedirect = &limit
+			if next {
+				i += 2
+				continue
+			}
+		} else if name := flagName(args[i]); name == "--allow-downgrade" || name == "--trust-server-names" {
+			if !CheckValidFlag(args[i], flags) {
+				return fmt.Errorf("invalid flag %s", args[i])
+			}
+			if name == "--allow-downgrade" {
+				components.AllowDowngrade = true
+			} else {
+				components.TrustServerNames = true
+			}
+		} else if name := flagName(args[i]); name == "--compression" || name == "--max-decompressed-size" {
+			value, next, err := CatchValue(args[i:], flags)
+			if err != nil {
+				return err
+			}
+			if name == "--compression" {
+				if value != "auto" && value != "gzip" && value != "none" {
+					return fmt.Errorf("invalid --compression %q, expected auto, gzip or none", value)
+				}
+				components.Compression = value
+			} else if components.MaxDecompressedSize, err = parseSize(value); err != nil {
+				return err
+			}
+			if next {
+				i += 2
+				continue
+			}
+		} else if strings.HasPrefix(args[i], "--keep-encoded") {
+			if !CheckValidFlag(args[i], flags) {
+				return fmt.Errorf("invalid flag %s", args[i])
+			}
+			components.KeepEncoded = true
+		} else if flagName(args[i]) == "--progress" {
+			value, next, err := CatchValue(args[i:], flags)
+			if err != nil {
+				return err
+			}
+			if components.Progress, components.DotStyle, err = parseProgress(value); err != nil {
+				return err
+			}
+			if next {
+				i += 2
+				continue
+			}
+		} else if strings.HasPrefix(args[i], "--show-progress") {
+			if !CheckValidFlag(args[i], flags) {
+				return fmt.Errorf("invalid flag %s", args[i])
+			}
+			components.ShowProgress = true
+		} else if flagName(args[i]) == "--pid-file" {
+			value, next, err := CatchValue(args[i:], flags)
+			if err != nil {
+				return err
+			}
+			components.PIDFile = value
+			if next {
+				i += 2
+				continue
+			}
+		} else if flagName(args[i]) == "--events" {
+			value, next, err := CatchValue(args[i:], flags)
+			if err != nil {
+				return err
+			}
+			if value != "json" {
+				return fmt.Errorf("invalid events format %q, only json is supported", value)
+			}
+			components.EventsFD = 1
+			if next {
+				i += 2
+				continue
+			}
+		} else if flagName(args[i]) == "--events-fd" {
+			value, next, err := CatchValue(args[i:], flags)
+			if err != nil {
+				return err
+			}
+			if components.EventsFD, err = parseEventsFD(value); err != nil {
+				return err
+			}
+			if next {
+				i += 2
+				continue
+			}
+		} else if flagName(args[i]) == "--report" {
+			value, next, err := CatchValue(args[i:], flags)
+			if err != nil {
+				return err
+			}
+			components.Report = value
+			if next {
+				i += 2
+				continue
+			}
+		} else if strings.HasPrefix(args[i], "--keep-partial") {
+			if !CheckValidFlag(args[i], flags) {
+				return fmt.Errorf("invalid flag %s", args[i])
+			}
+			components.KeepPartial = true
+		} else if strings.HasPrefix(args[i], "-c") || strings.HasPrefix(args[i], "--continue") {
+			if !CheckValidFlag(args[i], flags) {
+				return fmt.Errorf("invalid flag %s", args[i])
+			}
+			components.Continue = true
 		} else if strings.HasPrefix(args[i], "--convert-links") {
 			if !CheckValidFlag(args[i], flags) {
 				return errors.New("invalid flag --convert-links")
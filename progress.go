@@ -0,0 +1,333 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// dotStyle is one of wget's --progress=dot styles
+type dotStyle struct {
+	dot     int64 // bytes per dot
+	cluster int   // dots per cluster
+	line    int   // dots per line
+}
+
+var dotStyles = map[string]dotStyle{
+	"default": {dot: 1 << 10, cluster: 10, line: 50},
+	"binary":  {dot: 8 << 10, cluster: 16, line: 48},
+	"mega":    {dot: 64 << 10, cluster: 8, line: 48},
+	"giga":    {dot: 1 << 20, cluster: 8, line: 32},
+}
+
+// parseProgress reads --progress=bar, none, dot or dot:<style>
+func parseProgress(value string) (string, string, error) {
+	mode, style, _ := strings.Cut(value, ":")
+	switch mode {
+	case "bar", "none":
+		if style == "" {
+			return mode, "", nil
+		}
+	case "dot":
+		if style == "" {
+			style = "default"
+		}
+		if _, ok := dotStyles[style]; ok {
+			return mode, style, nil
+		}
+	}
+	return "", "", fmt.Errorf("invalid progress %q, use bar, dot[:default|binary|mega|giga] or none", value)
+}
+
+// isTerminal reports whether w is an interactive terminal
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+// terminalWidth is the width of the terminal behind w, 80 when unknown
+func terminalWidth(w io.Writer) int {
+	if f, ok := w.(*os.File); ok {
+		if width, _, err := term.GetSize(int(f.Fd())); err == nil && width > 0 {
+			return width
+		}
+	}
+	return 80
+}
+
+// speedHalfLife is how quickly the smoothed speed follows changes
+const speedHalfLife = 2 * time.Second
+
+// progress draws one transfer, as a bar redrawn in place on a terminal or
+// as wget's lines of dots anywhere else
+type progress struct {
+	c     *FlagsComponents
+	link  string
+	path  string
+	name  string
+	total int64 // -1 when unknown
+	mode  string
+	dots  dotStyle
+	width int
+	say   func(string)
+	start time.Time
+
+	// On a dashboard the bar is drawn by it, see dashboard.go
+	dash      *dashboard
+	nameWidth int
+
+	mu      sync.Mutex
+	written int64
+
+	// Smoothed speed in bytes/s, it drives the ETA
+	speed     float64
+	lastBytes int64
+	lastTime  time.Time
+	lastDraw  time.Time
+	lastEvent time.Time
+
+	// Dot mode: bytes drawn so far and the line being filled
+	drawn int64
+	line  strings.Builder
+}
+
+// newProgress picks the display for a transfer of total bytes into
+// filename. The bar needs a terminal, elsewhere it turns into dots unless
+// --show-progress. While a dashboard runs the bar becomes one of its rows
+func (c *FlagsComponents) newProgress(link, filename string, total int64, logger *log.Logger) *progress {
+	p := &progress{
+		c:     c,
+		link:  link,
+		path:  filename,
+		name:  filepath.Base(filename),
+		total: total,
+		mode:  c.Progress,
+		width: terminalWidth(Stdout),
+		say:   func(msg string) { logOrPrint(logger, c.Background, msg) },
+		start: time.Now(),
+	}
+	if p.mode == "" {
+		p.mode = "bar"
+	}
+	if p.mode == "bar" && c.dash != nil {
+		p.dash, p.nameWidth, p.width = c.dash, 20, c.dash.width
+		c.dash.add(p)
+		return p
+	}
+	// The -B log takes whole lines only, and so do events on the terminal
+	if p.mode == "bar" && (c.Background || c.eventsOnTerminal() || !isTerminal(Stdout) && !c.ShowProgress) {
+		p.mode = "dot"
+	}
+	p.dots = dotStyles["default"]
+	if style, ok := dotStyles[c.DotStyle]; ok {
+		p.dots = style
+	}
+	return p
+}
+
+// skip starts the display at written bytes, for resumed transfers
+func (p *progress) skip(written int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.written, p.lastBytes = written, written
+	p.drawn = written - written%(p.dots.dot*int64(p.dots.line))
+}
+
+// update records that written bytes arrived so far
+func (p *progress) update(written int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.written = written
+	now := time.Now()
+	if p.lastTime.IsZero() {
+		p.lastBytes, p.lastTime = written, now
+	} else if dt := now.Sub(p.lastTime); dt >= 250*time.Millisecond {
+		// Exponential moving average, weighted by the time each sample covers
+		rate := float64(written-p.lastBytes) / dt.Seconds()
+		if p.speed == 0 {
+			p.speed = rate
+		} else {
+			weight := 1 - math.Exp2(-dt.Seconds()/speedHalfLife.Seconds())
+			p.speed += weight * (rate - p.speed)
+		}
+		p.lastBytes, p.lastTime = written, now
+	}
+	if now.Sub(p.lastEvent) >= time.Second {
+		p.c.emit(event{Event: "progress", URL: p.link, Path: p.path, Bytes: written, Total: p.total})
+		p.lastEvent = now
+	}
+
+	switch {
+	case p.dash != nil:
+		// Drawn with the rest of the dashboard
+	case p.mode == "bar":
+		if now.Sub(p.lastDraw) >= 200*time.Millisecond {
+			p.say("\r" + p.bar(written, false))
+			p.lastDraw = now
+		}
+	case p.mode == "dot":
+		p.addDots(written)
+	}
+}
+
+// finish draws the last state of the transfer and ends its line
+func (p *progress) finish(written int64) {
+	if p.dash != nil {
+		// The "saved" line that follows is the record, the row just goes
+		p.dash.remove(p)
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch p.mode {
+	case "bar":
+		p.say("\r" + p.bar(written, true) + "\n")
+	case "dot":
+		p.addDots(written)
+		if p.line.Len() == 0 && written == p.drawn {
+			return
+		}
+		if p.line.Len() == 0 {
+			p.startLine()
+		}
+		// Pad the last line so its numbers line up with the ones above
+		for i := int(p.drawn/p.dots.dot) % p.dots.line; i < p.dots.line; i++ {
+			if i%p.dots.cluster == 0 {
+				p.line.WriteByte(' ')
+			}
+			p.line.WriteByte(' ')
+		}
+		p.endLine(written, fmt.Sprintf("=%s", formatElapsed(time.Since(p.start))))
+	}
+}
+
+func (p *progress) addDots(written int64) {
+	for p.drawn+p.dots.dot <= written {
+		n := int(p.drawn/p.dots.dot) % p.dots.line
+		if n == 0 {
+			p.startLine()
+		}
+		if n%p.dots.cluster == 0 {
+			p.line.WriteByte(' ')
+		}
+		p.line.WriteByte('.')
+		p.drawn += p.dots.dot
+		if n+1 == p.dots.line {
+			p.endLine(p.drawn, p.eta(p.drawn))
+		}
+	}
+}
+
+func (p *progress) startLine() {
+	p.line.Reset()
+	fmt.Fprintf(&p.line, "%6dK", p.drawn/1024)
+}
+
+// endLine adds the percentage, the speed and tail to the line
+func (p *progress) endLine(written int64, tail string) {
+	if p.total > 0 {
+		fmt.Fprintf(&p.line, " %3d%%", written*100/p.total)
+	}
+	fmt.Fprintf(&p.line, " %11s", formatSpeed(p.currentSpeed(written)/(1024*1024)))
+	if tail != "" {
+		p.line.WriteString(" " + tail)
+	}
+	p.say(p.line.String() + "\n")
+	p.line.Reset()
+}
+
+// currentSpeed is the smoothed speed, or the average until there is one
+func (p *progress) currentSpeed(written int64) float64 {
+	if p.speed > 0 {
+		return p.speed
+	}
+	if elapsed := time.Since(p.start).Seconds(); elapsed > 0 {
+		return float64(written) / elapsed
+	}
+	return 0
+}
+
+// eta is the time left at the smoothed speed, empty when it can't be told
+func (p *progress) eta(written int64) string {
+	if p.total <= 0 || p.speed <= 0 || written >= p.total {
+		return ""
+	}
+	return formatETA(time.Duration(float64(p.total-written) / p.speed * float64(time.Second)))
+}
+
+// pageProgress shows a mirror fetch on the dashboard. Without one nothing
+// is drawn, the mirror log has lines of its own for every page
+func (c *FlagsComponents) pageProgress(link string, total int64) *progress {
+	p := c.newProgress(link, GetOutputFromUrl(link), total, nil)
+	if p.dash == nil {
+		p.mode = "none"
+	}
+	return p
+}
+
+// bar renders one line that fits the terminal:
+// name  45% [=====>     ] 1.35MB  2.10 MB/s  eta 3s
+func (p *progress) bar(written int64, done bool) string {
+	name := p.name
+	if utf8.RuneCountInString(name) > 20 {
+		name = string([]rune(name)[:17]) + "..."
+	}
+	if p.nameWidth > 0 {
+		name = fmt.Sprintf("%-*s", p.nameWidth, name)
+	}
+	percent := "    "
+	if p.total > 0 {
+		percent = fmt.Sprintf("%3d%%", min(written*100/p.total, 100))
+	}
+	tail := "eta " + p.eta(written)
+	if tail == "eta " && p.total > 0 {
+		tail += "--"
+	}
+	if done {
+		tail = "in " + formatElapsed(time.Since(p.start))
+	}
+	speed := p.currentSpeed(written)
+	if done {
+		speed = float64(written) / time.Since(p.start).Seconds()
+	}
+	info := fmt.Sprintf(" %8s %12s  %-10s", humanSize(float64(written)), formatSpeed(speed/(1024*1024)), tail)
+
+	// Whatever is left of the terminal goes to the bar itself
+	barWidth := p.width - 1 - utf8.RuneCountInString(name) - 1 - len(percent) - 3 - len(info)
+	if barWidth < 10 {
+		return fitWidth(fmt.Sprintf("%s %s%s", name, percent, info), p.width)
+	}
+	var bar string
+	if p.total > 0 {
+		filled := int(min(written, p.total) * int64(barWidth) / p.total)
+		bar = strings.Repeat("=", filled)
+		if filled < barWidth {
+			bar += ">" + strings.Repeat(" ", barWidth-filled-1)
+		}
+	} else {
+		// Unknown size, bounce a <=> back and forth
+		pos := int(time.Since(p.start)/(100*time.Millisecond)) % (2 * (barWidth - 3))
+		if pos > barWidth-3 {
+			pos = 2*(barWidth-3) - pos
+		}
+		bar = strings.Repeat(" ", pos) + "<=>" + strings.Repeat(" ", barWidth-pos-3)
+	}
+	return fmt.Sprintf("%s %s [%s]%s", name, percent, bar, info)
+}
+
+// formatElapsed is a short duration, 1.2s or 3m05s
+func formatElapsed(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	}
+	return formatETA(d)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestParseProgress(t *testing.T) {
+	tests := []struct {
+		in, mode, style string
+		ok              bool
+	}{
+		{"bar", "bar", "", true},
+		{"none", "none", "", true},
+		{"dot", "dot", "default", true},
+		{"dot:mega", "dot", "mega", true},
+		{"dot:giga", "dot", "giga", true},
+		{"bar:force", "", "", false},
+		{"dot:huge", "", "", false},
+		{"dots", "", "", false},
+		{"", "", "", false},
+	}
+	for _, tt := range tests {
+		mode, style, err := parseProgress(tt.in)
+		if (err == nil) != tt.ok || mode != tt.mode || style != tt.style {
+			t.Errorf("parseProgress(%q) = %q, %q, %v, want %q, %q, ok %v", tt.in, mode, style, err, tt.mode, tt.style, tt.ok)
+		}
+	}
+}
+
+func TestBarFitsWidth(t *testing.T) {
+	names := []string{"a.bin", "exactly-twenty-chars", "a-file-with-a-very-long-name.tar.gz", "ünïcödé-nämé-thät-is-löng.iso"}
+	for _, width := range []int{40, 80, 132} {
+		for _, name := range names {
+			for _, total := range []int64{1 << 20, -1} {
+				p := &progress{name: name, total: total, width: width, start: time.Now().Add(-time.Second)}
+				for _, done := range []bool{false, true} {
+					line := p.bar(1<<19, done)
+					if !utf8.ValidString(line) {
+						t.Errorf("width %d, %q: bar cut a character in half: %q", width, name, line)
+					}
+					// Narrow terminals drop the bar and cut the rest
+					n := utf8.RuneCountInString(line)
+					if n > width-1 || strings.Contains(line, "[") && n != width-1 {
+						t.Errorf("width %d, %q, total %d: bar is %d columns, want %d: %q", width, name, total, n, width-1, line)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestBarFill(t *testing.T) {
+	p := &progress{name: "f", total: 100, width: 80, start: time.Now()}
+	brackets := regexp.MustCompile(`\[(.*)\]`)
+	tests := []struct {
+		written int64
+		percent string
+		filled  float64
+	}{
+		{0, "  0%", 0},
+		{50, " 50%", 0.5},
+		{100, "100%", 1},
+		{150, "100%", 1},
+	}
+	for _, tt := range tests {
+		line := p.bar(tt.written, false)
+		if !strings.Contains(line, tt.percent) {
+			t.Errorf("bar(%d) = %q, want %s", tt.written, line, tt.percent)
+		}
+		bar := brackets.FindStringSubmatch(line)[1]
+		if got := strings.Count(bar, "="); got != int(tt.filled*float64(len(bar))) {
+			t.Errorf("bar(%d) fills %d of %d", tt.written, got, len(bar))
+		}
+	}
+}
+
+func TestDotLines(t *testing.T) {
+	tests := []struct {
+		style          string
+		written, total int64
+		lines          []string
+	}{
+		// 50 dots of 1K make a full line, the rest waits for finish
+		{"default", 60 << 10, 60 << 10, []string{
+			`^     0K \.{10} \.{10} \.{10} \.{10} \.{10}  83% +\S+ \S+/s`,
+			`^    50K \.{10} {45}100% +\S+ \S+/s =\d+\.\ds$`,
+		}},
+		// A full last line needs no padded one after it
+		{"mega", 3 << 20, 4 << 20, []string{
+			`^     0K( \.{8}){6}  75% +\S+ \S+/s`,
+		}},
+		{"mega", 3<<20 + 1000, 3<<20 + 1000, []string{
+			`^     0K( \.{8}){6}  99% +\S+ \S+/s`,
+			`^  3072K {55}100% +\S+ \S+/s =\d+\.\ds$`,
+		}},
+		{"default", 512, 512, []string{
+			`^     0K {56}100% +\S+ \S+/s =\d+\.\ds$`,
+		}},
+	}
+	for _, tt := range tests {
+		var lines []string
+		p := &progress{
+			c:     &FlagsComponents{},
+			total: tt.total,
+			mode:  "dot",
+			dots:  dotStyles[tt.style],
+			say:   func(msg string) { lines = append(lines, strings.TrimSuffix(msg, "\n")) },
+			start: time.Now().Add(-time.Second),
+		}
+		p.update(tt.written)
+		p.finish(tt.written)
+		if len(lines) != len(tt.lines) {
+			t.Errorf("%s, %d bytes: got %d lines, want %d:\n%s", tt.style, tt.written, len(lines), len(tt.lines), strings.Join(lines, "\n"))
+			continue
+		}
+		for i, want := range tt.lines {
+			if !regexp.MustCompile(want).MatchString(lines[i]) {
+				t.Errorf("%s, %d bytes: line %d = %q, want %s", tt.style, tt.written, i, lines[i], want)
+			}
+		}
+	}
+}
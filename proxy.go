@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+)
+
+// proxyFor returns the proxy to reach target through, nil for a direct
+// connection. --proxy wins over http_proxy/https_proxy, no_proxy is honoured
+// either way and --no-proxy turns proxies off altogether
+func (c *FlagsComponents) proxyFor(target *url.URL) (*url.URL, error) {
+	if c.NoProxy {
+		return nil, nil
+	}
+	c.proxyOnce.Do(func() {
+		config := httpproxy.FromEnvironment()
+		if c.Proxy != "" {
+			config.HTTPProxy, config.HTTPSProxy = c.Proxy, c.Proxy
+		}
+		c.proxyFunc = config.ProxyFunc()
+	})
+
+	p, err := c.proxyFunc(target)
+	if err != nil || p == nil {
+		return nil, err
+	}
+	if c.ProxyUser != "" {
+		// ProxyFunc hands every caller the same cached URL, change a copy
+		u := *p
+		u.User = url.UserPassword(c.ProxyUser, c.ProxyPassword)
+		return &u, nil
+	}
+	return p, nil
+}
+
+// parseProxyURL checks a --proxy value, a bare host:port means an HTTP proxy
+func parseProxyURL(value string) (string, error) {
+	if !strings.Contains(value, "://") {
+		value = "http://" + value
+	}
+	p, err := url.Parse(value)
+	if err != nil || p.Host == "" {
+		return "", fmt.Errorf("invalid proxy: %s", value)
+	}
+	switch p.Scheme {
+	case "http", "https", "socks5", "socks5h":
+		return value, nil
+	}
+	return "", fmt.Errorf("unsupported proxy scheme %q, use http, https, socks5 or socks5h", p.Scheme)
+}
+
+func isSocks(p *url.URL) bool {
+	return p != nil && (p.Scheme == "socks5" || p.Scheme == "socks5h")
+}
+
+// httpProxy is the http.Transport hook, SOCKS proxies are left to dialContext
+func (c *FlagsComponents) httpProxy(req *http.Request) (*url.URL, error) {
+	p, err := c.proxyFor(req.URL)
+	if err != nil || isSocks(p) {
+		return nil, err
+	}
+	return p, nil
+}
+
+// socksProxyFor finds the SOCKS proxy for a connection to addr made for a
+// URL of scheme, if any
+func (c *FlagsComponents) socksProxyFor(scheme, addr string) *url.URL {
+	p, err := c.proxyFor(&url.URL{Scheme: scheme, Host: addr})
+	if err != nil || !isSocks(p) {
+		return nil
+	}
+	return p
+}
+
+// dialSocks connects to addr through a SOCKS5 proxy. socks5h lets the proxy
+// resolve the name, plain socks5 resolves it here first
+func (c *FlagsComponents) dialSocks(ctx context.Context, p *url.URL, network, addr string) (net.Conn, error) {
+	var auth *proxy.Auth
+	if p.User != nil {
+		password, _ := p.User.Password()
+		auth = &proxy.Auth{User: p.User.Username(), Password: password}
+	}
+	proxyAddr := p.Host
+	if p.Port() == "" {
+		proxyAddr = net.JoinHostPort(p.Hostname(), "1080")
+	}
+	dialer, err := proxy.SOCKS5("tcp", proxyAddr, auth, directDialer{c})
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Scheme == "socks5" {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		ips, err := c.lookupIP(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		addr = net.JoinHostPort(ips[0].String(), port)
+	}
+
+	conn, err := dialer.(proxy.ContextDialer).DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("socks proxy %s: %w", p.Redacted(), err)
+	}
+	return conn, nil
+}
+
+// directDialer lets the SOCKS client reach the proxy with our own timeouts
+type directDialer struct {
+	c *FlagsComponents
+}
+
+func (d directDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.c.dialDirect(context.Background(), network, addr)
+}
+
+func (d directDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return d.c.dialDirect(ctx, network, addr)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/url"
+	"sync"
+	"testing"
+)
+
+func TestSocksProxyForScheme(t *testing.T) {
+	for _, name := range []string{"http_proxy", "HTTP_PROXY", "no_proxy", "NO_PROXY", "REQUEST_METHOD"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("https_proxy", "socks5://proxy.example:1080")
+	t.Setenv("HTTPS_PROXY", "socks5://proxy.example:1080")
+
+	tests := []struct {
+		scheme, addr string
+		socks        bool
+	}{
+		{"https", "example.com:443", true},
+		{"https", "example.com:8443", true},
+		{"http", "example.com:80", false},
+		{"http", "example.com:443", false},
+	}
+	c := &FlagsComponents{}
+	for _, tt := range tests {
+		if got := c.socksProxyFor(tt.scheme, tt.addr); (got != nil) != tt.socks {
+			t.Errorf("socksProxyFor(%q, %q) = %v, want SOCKS proxy: %v", tt.scheme, tt.addr, got, tt.socks)
+		}
+	}
+}
+
+func TestProxyForConcurrent(t *testing.T) {
+	t.Setenv("no_proxy", "")
+	t.Setenv("NO_PROXY", "")
+	c := &FlagsComponents{Proxy: "http://proxy.example:3128", ProxyUser: "user", ProxyPassword: "secret"}
+	target, _ := url.Parse("http://example.com/file.bin")
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				p, err := c.proxyFor(target)
+				if err != nil || p == nil || p.User.Username() != "user" {
+					t.Errorf("proxyFor = %v, %v", p, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestParseProxyURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+		ok       bool
+	}{
+		{"proxy.example:3128", "http://proxy.example:3128", true},
+		{"https://proxy.example", "https://proxy.example", true},
+		{"socks5h://user:pw@proxy.example:1080", "socks5h://user:pw@proxy.example:1080", true},
+		{"ftp://proxy.example", "", false},
+		{"http://", "", false},
+	}
+	for _, tt := range tests {
+		got, err := parseProxyURL(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseProxyURL(%q) = %q, %v, want %q, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// parseSize reads sizes like -Q 500m: bytes, or with a k, m or g suffix;
+// 0 and inf mean no limit
+func parseSize(value string) (int64, error) {
+	s := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(value)), "b")
+	if s == "inf" {
+		return 0, nil
+	}
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(s, "k"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(s, "m"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(s, "g"):
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %s", value)
+	}
+	return n * multiplier, nil
+}
+
+// quotaExceeded tells callers not to start another download. Whatever is
+// already running finishes, like wget the quota is checked between files
+func (c *FlagsComponents) quotaExceeded() bool {
+	return c.Quota > 0 && c.retrieved.Load() >= c.Quota
+}
+
+// skipForQuota records a download that never started because of -Q
+func (c *FlagsComponents) skipForQuota() bool {
+	if !c.quotaExceeded() {
+		return false
+	}
+	c.quotaSkipped.Add(1)
+	return true
+}
+
+// quotaError is the summary line once the quota stopped downloads
+func (c *FlagsComponents) quotaError() error {
+	if c.quotaSkipped.Load() == 0 {
+		return nil
+	}
+	return fmt.Errorf("download quota of %s EXCEEDED after %s, %d URLs skipped",
+		humanSize(float64(c.Quota)), humanSize(float64(c.retrieved.Load())), c.quotaSkipped.Load())
+}
+
+// countRetrieved adds what is read from body to the bytes -Q counts
+func (c *FlagsComponents) countRetrieved(body io.ReadCloser) io.ReadCloser {
+	return &countingBody{ReadCloser: body, c: c}
+}
+
+type countingBody struct {
+	io.ReadCloser
+	c *FlagsComponents
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.c.retrieved.Add(int64(n))
+	return n, err
+}
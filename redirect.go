@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// defaultMaxRedirect is wget's limit, Go's own stops at 10
+const defaultMaxRedirect = 20
+
+// checkRedirect is the redirect policy of every client: it logs each hop,
+// enforces --max-redirect and refuses HTTPS to HTTP unless --allow-downgrade
+func (c *FlagsComponents) checkRedirect(req *http.Request, via []*http.Request) error {
+	limit := defaultMaxRedirect
+	if c.MaxRedirect != nil {
+		limit = *c.MaxRedirect
+	}
+	if len(via) > limit {
+		return fmt.Errorf("%d redirections exceeded", limit)
+	}
+
+	previous := via[len(via)-1]
+	if previous.URL.Scheme == "https" && req.URL.Scheme == "http" && !c.AllowDowngrade {
+		return fmt.Errorf("refusing to follow redirect from %s to insecure %s, use --allow-downgrade to allow it", previous.URL, req.URL)
+	}
+
+	status, code := "", 0
+	if req.Response != nil {
+		status, code = req.Response.Status+", ", req.Response.StatusCode
+	}
+	c.say(fmt.Sprintf("%sLocation: %s [following]\n", status, req.URL))
+	c.emit(event{Event: "redirect", URL: previous.URL.String(), Status: code, Location: req.URL.String()})
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// redirectServers is a plain server whose /r/N redirects N more times
+// before answering, and a TLS one whose /down redirects to the plain one
+func redirectServers(t *testing.T) (plain, secure *httptest.Server) {
+	plain = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/r/"))
+		if n > 0 {
+			http.Redirect(w, r, fmt.Sprintf("/r/%d", n-1), http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, "done")
+	}))
+	secure = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/down":
+			http.Redirect(w, r, plain.URL+"/r/0", http.StatusMovedPermanently)
+		case "/same":
+			http.Redirect(w, r, "/end", http.StatusFound)
+		default:
+			fmt.Fprint(w, "secure")
+		}
+	}))
+	t.Cleanup(plain.Close)
+	t.Cleanup(secure.Close)
+	return plain, secure
+}
+
+func TestCheckRedirect(t *testing.T) {
+	plain, secure := redirectServers(t)
+	limit := func(n int) *int { return &n }
+	tests := []struct {
+		name      string
+		url       string
+		max       *int
+		downgrade bool
+		want      string // body, or part of the error
+		hops      int
+	}{
+		{"default follows 20", plain.URL + "/r/20", nil, false, "done", 20},
+		{"default stops after 20", plain.URL + "/r/21", nil, false, "20 redirections exceeded", 20},
+		{"--max-redirect=0", plain.URL + "/r/1", limit(0), false, "0 redirections exceeded", 0},
+		{"--max-redirect=2 follows 2", plain.URL + "/r/2", limit(2), false, "done", 2},
+		{"--max-redirect=2 stops at 3", plain.URL + "/r/3", limit(2), false, "2 redirections exceeded", 2},
+		{"https to http refused", secure.URL + "/down", nil, false, "refusing to follow redirect", 0},
+		{"--allow-downgrade", secure.URL + "/down", nil, true, "done", 1},
+		{"https to https", secure.URL + "/same", nil, false, "secure", 1},
+	}
+	for _, tt := range tests {
+		var logged bytes.Buffer
+		c := &FlagsComponents{MaxRedirect: tt.max, AllowDowngrade: tt.downgrade, Background: true, logger: log.New(&logged, "", 0)}
+		client := secure.Client()
+		client.CheckRedirect = c.checkRedirect
+
+		var got string
+		resp, err := client.Get(tt.url)
+		if err != nil {
+			got = err.Error()
+		} else {
+			var body bytes.Buffer
+			body.ReadFrom(resp.Body)
+			resp.Body.Close()
+			got = body.String()
+		}
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+		if hops := strings.Count(logged.String(), "[following]"); hops != tt.hops {
+			t.Errorf("%s: followed %d redirects, want %d", tt.name, hops, tt.hops)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const defaultUserAgent = "Mozilla/5.0 (compatible; Wget/1.21)"
+
+// newRequest builds every request we send, for single downloads and for
+// the mirror. An empty method means the user's --method and body, internal
+// requests like HEAD probes and range fetches pass their own
+func (c *FlagsComponents) newRequest(method, link string) (*http.Request, error) {
+	var body io.Reader
+	if method == "" {
+		method = c.requestMethod()
+		data, err := c.requestBody()
+		if err != nil {
+			return nil, err
+		}
+		if data != nil {
+			// bytes.Reader lets redirects and retries replay the body
+			body = bytes.NewReader(data)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(c.context(), method, link, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", defaultUserAgent)
+	if c.UserAgent != nil {
+		// An empty --user-agent sends no User-Agent at all
+		req.Header.Set("User-Agent", *c.UserAgent)
+	}
+	if c.Referer != "" {
+		req.Header.Set("Referer", c.Referer)
+	}
+	if body != nil && (c.PostData != "" || c.PostFile != "") {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	setHeaders(req, c.Headers)
+	if entry := c.batchOptions[link]; entry != nil {
+		// header= lines of an -i file come on top of --header
+		setHeaders(req, entry.headers)
+	}
+	return req, nil
+}
+
+// setHeaders replaces the request's headers with these, Host sets req.Host
+func setHeaders(req *http.Request, headers http.Header) {
+	for name, values := range headers {
+		if name == "Host" {
+			req.Host = values[len(values)-1]
+			continue
+		}
+		req.Header.Del(name)
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+}
+
+// requestMethod is --method, or POST when there is a request body
+func (c *FlagsComponents) requestMethod() string {
+	if c.Method != "" {
+		return c.Method
+	}
+	if c.PostData != "" || c.PostFile != "" {
+		return http.MethodPost
+	}
+	return http.MethodGet
+}
+
+func (c *FlagsComponents) requestBody() ([]byte, error) {
+	switch {
+	case c.PostFile != "":
+		data, err := os.ReadFile(c.PostFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read post file: %v", err)
+		}
+		return data, nil
+	case c.PostData != "":
+		return []byte(c.PostData), nil
+	case c.BodyData != "":
+		return []byte(c.BodyData), nil
+	}
+	return nil, nil
+}
+
+// parseHeader adds one --header="Name: value" to headers
+func parseHeader(value string, headers http.Header) error {
+	name, val, ok := strings.Cut(value, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return fmt.Errorf("invalid header %q, expected \"Name: value\"", value)
+	}
+	headers.Add(name, strings.TrimSpace(val))
+	return nil
+}
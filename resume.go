@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ResumeState is kept next to a partial download so a later -c run can
+// ask the server for the rest of the same version of the resource
+type ResumeState struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Size         int64     `json:"size,omitempty"`
+	Segments     []Segment `json:"segments,omitempty"`
+}
+
+// matches reports whether a saved state describes the same version of the
+// resource as the one the server is offering now
+func (s *ResumeState) matches(other *ResumeState) bool {
+	if s.URL != other.URL || s.Size != other.Size || len(s.Segments) == 0 {
+		return false
+	}
+	if s.ETag != "" || other.ETag != "" {
+		return s.ETag == other.ETag
+	}
+	return s.LastModified == other.LastModified
+}
+
+func resumeStateFile(filename string) string {
+	return filename + ".wget-state"
+}
+
+func loadResumeState(filename string) *ResumeState {
+	data, err := os.ReadFile(resumeStateFile(filename))
+	if err != nil {
+		return nil
+	}
+	var state ResumeState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil
+	}
+	return &state
+}
+
+func saveResumeState(filename string, state *ResumeState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(resumeStateFile(filename), data, 0o644)
+}
+
+func removeResumeState(filename string) {
+	os.Remove(resumeStateFile(filename))
+}
+
+// resumeTarget is the file -c continues: the .part file of an earlier run,
+// or else a file already under the final name, say from another tool. That
+// one is extended in place like wget does, so a failed attempt never takes
+// it away from its name
+func resumeTarget(filename string) string {
+	if _, err := os.Stat(partName(filename)); err == nil {
+		return partName(filename)
+	}
+	if info, err := os.Stat(filename); err == nil && info.Mode().IsRegular() {
+		return filename
+	}
+	return partName(filename)
+}
+
+// partialFor finds where an earlier run of link left its partial download.
+// That run may have had to take a file.1 style name, a rerun derives the
+// plain name again and would otherwise never find it
+func partialFor(filename, link string) string {
+	dir, ext := filepath.Dir(filename), filepath.Ext(filename)
+	base := strings.TrimSuffix(filepath.Base(filename), ext)
+	name := filename
+	for i := 1; ; i++ {
+		if _, err := os.Stat(partName(name)); err == nil {
+			// A .part without state under the plain name is an old style -c
+			state := loadResumeState(name)
+			if state != nil && state.URL == link || state == nil && name == filename {
+				return name
+			}
+		} else if _, err := os.Stat(name); err != nil {
+			// freeName numbers from 1 up, nothing further along
+			return filename
+		}
+		name = filepath.Join(dir, fmt.Sprintf("%s.%d%s", base, i, ext))
+	}
+}
+
+// partialDownload returns the size of the file -c continues and the
+// validators recorded for it, if they belong to the same URL
+func partialDownload(filename, link string) (int64, *ResumeState) {
+	info, err := os.Stat(resumeTarget(filename))
+	if err != nil || !info.Mode().IsRegular() {
+		return 0, nil
+	}
+	state := loadResumeState(filename)
+	if state != nil && state.URL != link {
+		state = nil
+	}
+	return info.Size(), state
+}
+
+// setRangeHeaders asks for the bytes after offset, guarded by If-Range so a
+// changed resource comes back whole instead of being spliced onto old data
+func setRangeHeaders(req *http.Request, offset int64, state *ResumeState) {
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	setIfRange(req, state)
+}
+
+func setIfRange(req *http.Request, state *ResumeState) {
+	if state == nil {
+		return
+	}
+	// If-Range only accepts strong validators
+	if state.ETag != "" && !strings.HasPrefix(state.ETag, "W/") {
+		req.Header.Set("If-Range", state.ETag)
+	} else if state.LastModified != "" {
+		req.Header.Set("If-Range", state.LastModified)
+	}
+}
+
+// contentRangeStart parses the first byte position out of a
+// "bytes first-last/complete" Content-Range header
+func contentRangeStart(header string) (int64, bool) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes ")
+	if !ok {
+		return 0, false
+	}
+	first, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, false
+	}
+	start, err := strconv.ParseInt(strings.TrimSpace(first), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return start, true
+}
+
+// openForResume appends to the partial file when the server honoured the
+// range, and truncates it when the download has to start over
+func openForResume(filename string, offset int64) (*os.File, error) {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if offset > 0 {
+		flags = os.O_WRONLY | os.O_APPEND
+	}
+	out, err := os.OpenFile(filename, flags, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %v", err)
+	}
+	return out, nil
+}
+
+// mirrorState is written when a mirror is interrupted: the pages saved so
+// far and the links found on them, so mirror -c follows those links again
+// without fetching the pages themselves
+type mirrorState struct {
+	URL   string                 `json:"url"`
+	Pages map[string]*mirrorPage `json:"pages"`
+}
+
+type mirrorPage struct {
+	Path  string   `json:"path"`
+	Links []string `json:"links,omitempty"`
+}
+
+// mirrorStateFile sits with the pages of root
+func (m *FlagsComponents) mirrorStateFile(root *url.URL) string {
+	return filepath.Join(m.BaseDir, root.Host, ".wget-mirror-state")
+}
+
+// loadMirrorState starts a mirror of root, with -c from where an
+// interrupted run of the same URL stopped
+func (m *FlagsComponents) loadMirrorState(root *url.URL) {
+	m.savedMu.Lock()
+	defer m.savedMu.Unlock()
+	m.saved = make(map[string]*mirrorPage)
+	m.resumed = nil
+	if !m.Continue {
+		return
+	}
+	data, err := os.ReadFile(m.mirrorStateFile(root))
+	if err != nil {
+		return
+	}
+	var state mirrorState
+	if json.Unmarshal(data, &state) == nil && state.URL == root.String() {
+		// Pages this run doesn't get to stay in the state for the next one
+		m.resumed = state.Pages
+		for link, page := range state.Pages {
+			m.saved[link] = page
+		}
+	}
+}
+
+// resumedPage is the page saved for link before mirror -c, if it is still there
+func (m *FlagsComponents) resumedPage(link string) *mirrorPage {
+	m.savedMu.Lock()
+	page := m.resumed[link]
+	m.savedMu.Unlock()
+	if page == nil {
+		return nil
+	}
+	if info, err := os.Stat(page.Path); err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+	return page
+}
+
+// savedPage notes a page that is on disk and whose links are known
+func (m *FlagsComponents) savedPage(link string, page *mirrorPage) {
+	m.savedMu.Lock()
+	m.saved[link] = page
+	m.savedMu.Unlock()
+}
+
+// saveMirrorState keeps the progress of an interrupted mirror of root for
+// mirror -c, a finished mirror removes it again
+func (m *FlagsComponents) saveMirrorState(root *url.URL) error {
+	m.savedMu.Lock()
+	data, err := json.MarshalIndent(&mirrorState{URL: root.String(), Pages: m.saved}, "", "  ")
+	m.savedMu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(m.mirrorStateFile(root), data); err != nil {
+		return fmt.Errorf("failed to save the mirror state: %v", err)
+	}
+	return nil
+}
+
+func (m *FlagsComponents) removeMirrorState(root *url.URL) {
+	os.Remove(m.mirrorStateFile(root))
+}
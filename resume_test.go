@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMirrorState(t *testing.T) {
+	dir := t.TempDir()
+	root, _ := url.Parse("http://example.com/site/")
+	page := filepath.Join(dir, "example.com", "site", "index.html")
+	os.MkdirAll(filepath.Dir(page), 0o755)
+	os.WriteFile(page, []byte("<html></html>"), 0o644)
+
+	m := &FlagsComponents{BaseDir: dir}
+	m.loadMirrorState(root)
+	m.savedPage(root.String(), &mirrorPage{Path: page, Links: []string{"http://example.com/site/a.html"}})
+	m.savedPage("http://example.com/site/gone.png", &mirrorPage{Path: filepath.Join(dir, "gone.png")})
+	if err := m.saveMirrorState(root); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without -c the state is ignored
+	m = &FlagsComponents{BaseDir: dir}
+	m.loadMirrorState(root)
+	if m.resumedPage(root.String()) != nil {
+		t.Error("a mirror without -c resumed")
+	}
+
+	m = &FlagsComponents{BaseDir: dir, Continue: true}
+	m.loadMirrorState(root)
+	got := m.resumedPage(root.String())
+	if got == nil || !reflect.DeepEqual(got.Links, []string{"http://example.com/site/a.html"}) {
+		t.Errorf("resumedPage = %+v, want the saved page and its links", got)
+	}
+	if m.resumedPage("http://example.com/site/gone.png") != nil {
+		t.Error("a page missing on disk was resumed")
+	}
+	if m.resumedPage("http://example.com/site/a.html") != nil {
+		t.Error("a page never saved was resumed")
+	}
+
+	// Another root URL doesn't pick up the state
+	other, _ := url.Parse("http://example.com/other/")
+	m = &FlagsComponents{BaseDir: dir, Continue: true}
+	m.loadMirrorState(other)
+	if m.resumedPage(root.String()) != nil {
+		t.Error("the state of another mirror was used")
+	}
+
+	m.removeMirrorState(root)
+	if _, err := os.Stat(m.mirrorStateFile(root)); !os.IsNotExist(err) {
+		t.Errorf("state file still there: %v", err)
+	}
+}
+
+func TestContentRangeStart(t *testing.T) {
+	tests := []struct {
+		header string
+		want   int64
+		ok     bool
+	}{
+		{"bytes 100-199/200", 100, true},
+		{"bytes 0-0/1", 0, true},
+		{" bytes 5-9/* ", 5, true},
+		{"bytes */200", 0, false},
+		{"bytes x-9/10", 0, false},
+		{"items 1-2/3", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := contentRangeStart(tt.header)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("contentRangeStart(%q) = %d, %v, want %d, %v", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestResumeTarget(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "file.iso")
+	if got := resumeTarget(filename); got != partName(filename) {
+		t.Errorf("nothing there: resumeTarget = %s, want the .part file", got)
+	}
+	os.WriteFile(filename, []byte("abc"), 0o644)
+	if got := resumeTarget(filename); got != filename {
+		t.Errorf("final file only: resumeTarget = %s, want it resumed in place", got)
+	}
+	if size, _ := partialDownload(filename, "http://example.com/file.iso"); size != 3 {
+		t.Errorf("partialDownload size = %d, want 3", size)
+	}
+	if _, err := os.Stat(filename); err != nil {
+		t.Errorf("partialDownload moved the final file: %v", err)
+	}
+	os.WriteFile(partName(filename), []byte("a"), 0o644)
+	if got := resumeTarget(filename); got != partName(filename) {
+		t.Errorf("both there: resumeTarget = %s, want the .part file", got)
+	}
+}
+
+func TestPartialFor(t *testing.T) {
+	const link = "http://example.com/file.bin"
+	tests := []struct {
+		name  string
+		files map[string]string // name -> URL of its state, "" for none
+		want  string
+	}{
+		{"nothing there", nil, "file.bin"},
+		{"plain .part", map[string]string{"file.bin.part": link}, "file.bin"},
+		{"old style .part without state", map[string]string{"file.bin.part": ""}, "file.bin"},
+		{"numbered .part", map[string]string{"file.bin": "", "file.1.bin": "", "file.2.bin.part": link}, "file.2.bin"},
+		{"numbered .part of another URL", map[string]string{"file.bin": "", "file.1.bin.part": "http://example.com/other"}, "file.bin"},
+		{"plain .part of another URL", map[string]string{"file.bin.part": "http://example.com/other", "file.1.bin.part": link}, "file.1.bin"},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		for name, url := range tt.files {
+			os.WriteFile(filepath.Join(dir, name), []byte("data"), 0o644)
+			if url != "" {
+				saveResumeState(filepath.Join(dir, strings.TrimSuffix(name, ".part")), &ResumeState{URL: url})
+			}
+		}
+		if got := partialFor(filepath.Join(dir, "file.bin"), link); got != filepath.Join(dir, tt.want) {
+			t.Errorf("%s: partialFor = %s, want %s", tt.name, filepath.Base(got), tt.want)
+		}
+	}
+}
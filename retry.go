@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// httpStatusError is a response we could not use, it keeps the status and
+// the server's Retry-After hint for the retry policy
+type httpStatusError struct {
+	Code       int
+	Status     string
+	RetryAfter time.Duration
+	at         time.Time
+}
+
+func newHTTPStatusError(resp *http.Response) *httpStatusError {
+	return &httpStatusError{
+		Code:       resp.StatusCode,
+		Status:     resp.Status,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		at:         time.Now(),
+	}
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("--%s--  Error %d: %s", e.at.Format("2006-01-02 15:04:05"), e.Code, e.Status)
+}
+
+// truncatedError is a body that ended before Content-Length said it would
+type truncatedError struct {
+	got, want int64
+}
+
+func (e *truncatedError) Error() string {
+	return fmt.Sprintf("connection closed at byte %d of %d", e.got, e.want)
+}
+
+// maxRetryAfter caps the wait a server can ask for, unless --waitretry is longer
+const maxRetryAfter = 5 * time.Minute
+
+// parseRetryAfter understands both forms of Retry-After, delay-seconds and an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		if d := time.Until(when); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// parseStatusList reads a comma separated list of HTTP status codes
+func parseStatusList(value string) ([]int, error) {
+	var codes []int
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		code, err := strconv.Atoi(part)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid HTTP status code: %s", part)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// retryable decides if err is worth another attempt and how long the server
+// asked us to wait before it
+func (c *FlagsComponents) retryable(err error) (bool, time.Duration) {
+	// Past --deadline nothing is worth another try
+	if c.context().Err() != nil {
+		return false, 0
+	}
+
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return slices.Contains(c.RetryOnHTTPError, statusErr.Code), statusErr.RetryAfter
+	}
+
+	var truncated *truncatedError
+	var slow *lowSpeedError
+	if errors.As(err, &truncated) || errors.As(err, &slow) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true, 0
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return c.RetryConnRefused, 0
+	}
+
+	// An unknown host will not appear by asking again
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary, 0
+	}
+
+	// Timeouts and dropped connections. Not TLS or certificate failures,
+	// bad URLs or local file errors, another try ends the same way
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true, 0
+	}
+	var temporary interface{ Temporary() bool }
+	if errors.As(err, &temporary) && temporary.Temporary() {
+		return true, 0
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE) {
+		return true, 0
+	}
+	return false, 0
+}
+
+// backoff doubles the wait on every attempt up to --waitretry and adds
+// jitter so parallel clients don't retry in lockstep
+func (c *FlagsComponents) backoff(attempt int) time.Duration {
+	limit := c.WaitRetry
+	if limit <= 0 {
+		limit = 10 * time.Second
+	}
+	wait := time.Second << (attempt - 1)
+	if wait <= 0 || wait > limit {
+		wait = limit
+	}
+	half := wait / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// retry runs attempt at link up to --tries times, say prints the retry notices
+func (c *FlagsComponents) retry(link string, say func(string), attempt func() error) error {
+	tries := c.Tries
+	if tries < 1 {
+		tries = 1
+	}
+	for n := 1; ; n++ {
+		err := attempt()
+		if err == nil || n >= tries {
+			return err
+		}
+		ok, wait := c.retryable(err)
+		if !ok {
+			return err
+		}
+		if wait == 0 {
+			wait = c.backoff(n)
+		} else if limit := max(c.WaitRetry, maxRetryAfter); wait > limit {
+			wait = limit
+		}
+		say(fmt.Sprintf("%v\nRetrying in %s (try %d of %d).\n\n", err, wait.Round(100*time.Millisecond), n+1, tries))
+		c.emit(event{Event: "retry", URL: link, Attempt: n + 1, Wait: wait.Seconds(), Error: err.Error()})
+		select {
+		case <-time.After(wait):
+		case <-c.context().Done():
+			return err
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value    string
+		min, max time.Duration
+	}{
+		{"", 0, 0},
+		{"120", 120 * time.Second, 120 * time.Second},
+		{" 5 ", 5 * time.Second, 5 * time.Second},
+		{"0", 0, 0},
+		{"-3", 0, 0},
+		{"soon", 0, 0},
+		{time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), 58 * time.Second, time.Minute},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got < tt.min || got > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %s, want between %s and %s", tt.value, got, tt.min, tt.max)
+		}
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestRetryable(t *testing.T) {
+	urlErr := func(err error) error { return &url.Error{Op: "Get", URL: "https://example.com/", Err: err} }
+	tests := []struct {
+		name      string
+		err       error
+		connRetry bool
+		want      bool
+	}{
+		{"503 asked for", &httpStatusError{Code: 503}, false, true},
+		{"404", &httpStatusError{Code: 404}, false, false},
+		{"truncated", &truncatedError{got: 1, want: 2}, false, true},
+		{"unexpected EOF", fmt.Errorf("download failed: %w", io.ErrUnexpectedEOF), false, true},
+		{"timeout", urlErr(&net.OpError{Op: "read", Err: timeoutError{}}), false, true},
+		{"reset", urlErr(&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}), false, true},
+		{"refused", urlErr(&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}), false, false},
+		{"refused with --retry-connrefused", urlErr(&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}), true, true},
+		{"unknown host", urlErr(&net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", IsNotFound: true}}), false, false},
+		{"dns timeout", urlErr(&net.DNSError{Err: "timeout", IsTimeout: true}), false, true},
+		{"tls alert", urlErr(&net.OpError{Op: "remote error", Err: tls.AlertError(40)}), false, false},
+		{"bad certificate", urlErr(x509.UnknownAuthorityError{}), false, false},
+		{"local file", &os.PathError{Op: "open", Path: "x", Err: syscall.EACCES}, false, false},
+	}
+	for _, tt := range tests {
+		c := &FlagsComponents{RetryOnHTTPError: []int{503}, RetryConnRefused: tt.connRetry}
+		if got, _ := c.retryable(tt.err); got != tt.want {
+			t.Errorf("%s: retryable(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryCapsRetryAfter(t *testing.T) {
+	tests := []struct {
+		waitRetry time.Duration
+		want      string
+	}{
+		{0, "Retrying in 5m0s"},
+		{10 * time.Minute, "Retrying in 10m0s"},
+	}
+	for _, tt := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		c := &FlagsComponents{Tries: 2, WaitRetry: tt.waitRetry, RetryOnHTTPError: []int{503}, ctx: ctx}
+		var said strings.Builder
+		c.retry("http://example.com/", func(msg string) { said.WriteString(msg) }, func() error {
+			return &httpStatusError{Code: 503, RetryAfter: 999999 * time.Second}
+		})
+		cancel()
+		if !strings.Contains(said.String(), tt.want) {
+			t.Errorf("--waitretry=%s: said %q, want %q", tt.waitRetry, said.String(), tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	c := &FlagsComponents{WaitRetry: 4 * time.Second}
+	for attempt, limit := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second, 4 * time.Second} {
+		for range 20 {
+			if got := c.backoff(attempt + 1); got < limit/2 || got > limit {
+				t.Fatalf("backoff(%d) = %s, want between %s and %s", attempt+1, got, limit/2, limit)
+			}
+		}
+	}
+}
+
+func TestParseStatusList(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []int
+		ok   bool
+	}{
+		{"503", []int{503}, true},
+		{"429, 503,504", []int{429, 503, 504}, true},
+		{"", nil, true},
+		{"503,,", []int{503}, true},
+		{"99", nil, false},
+		{"600", nil, false},
+		{"5xx", nil, false},
+	}
+	for _, tt := range tests {
+		got, err := parseStatusList(tt.in)
+		if (err == nil) != tt.ok || fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("parseStatusList(%q) = %v, %v, want %v, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Segment is one byte range of a segmented download, End is inclusive
+type Segment struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	Done  int64 `json:"done"`
+}
+
+func (s *Segment) complete() bool {
+	return s.Start+s.Done > s.End
+}
+
+// splitSegments cuts size bytes into n ranges of about the same length
+func splitSegments(size int64, n int) []Segment {
+	if int64(n) > size {
+		n = int(size)
+	}
+	segments := make([]Segment, 0, n)
+	chunk := size / int64(n)
+	var start int64
+	for i := 0; i < n; i++ {
+		end := start + chunk - 1
+		if i == n-1 {
+			end = size - 1
+		}
+		segments = append(segments, Segment{Start: start, End: end})
+		start = end + 1
+	}
+	return segments
+}
+
+// acceptsRanges reports whether Accept-Ranges offers byte ranges
+func acceptsRanges(header http.Header) bool {
+	for _, value := range strings.Split(header.Get("Accept-Ranges"), ",") {
+		if strings.EqualFold(strings.TrimSpace(value), "bytes") {
+			return true
+		}
+	}
+	return false
+}
+
+// downloadSegmented fetches Link over several connections at once and
+// returns the path it wrote to. It returns false without error when the
+// server can't serve byte ranges so the caller falls back to a single stream
+func downloadSegmented(Link string, c *FlagsComponents, filename string, logger *log.Logger, Overide bool, sum *checksum) (string, bool, error) {
+	headReq, err := c.newRequest("HEAD", Link)
+	if err != nil {
+		return "", true, err
+	}
+	head, err := c.Client.Do(headReq)
+	if err != nil {
+		if c.context().Err() != nil {
+			return "", true, err
+		}
+		// Plenty of servers refuse HEAD, a plain GET may still work
+		logOrPrint(logger, c.Background, fmt.Sprintf("HEAD request failed (%v), downloading over a single connection.\n", err))
+		return "", false, nil
+	}
+	c.emit(event{Event: "response", URL: Link, Status: head.StatusCode, Total: head.ContentLength})
+	head.Body.Close()
+
+	if head.StatusCode != http.StatusOK || !acceptsRanges(head.Header) || head.ContentLength <= 0 {
+		logOrPrint(logger, c.Background, "Server does not support byte ranges, downloading over a single connection.\n")
+		return "", false, nil
+	}
+
+	size := head.ContentLength
+	contentType := head.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	logOrPrint(logger, c.Background, fmt.Sprintf("HTTP request sent, awaiting response... %s\n", head.Status))
+	if local := localCopy(filename); c.Timestamping && local != nil && upToDate(head.Header, size, local) {
+		logOrPrint(logger, c.Background, fmt.Sprintf("Server file no newer than local file '%s' -- not retrieving.\n\n", filename))
+		c.emit(event{Event: "saved", URL: Link, Path: filename, Status: head.StatusCode, Reason: "not modified"})
+		return "", true, nil
+	}
+	logOrPrint(logger, c.Background, fmt.Sprintf("Length: %d [%s]\n", size, contentType))
+
+	if !Overide {
+		filename = c.nameFromServer(head, filename, func(msg string) { logOrPrint(logger, c.Background, msg) })
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		return "", true, fmt.Errorf("failed to create directory: %v", err)
+	}
+
+	state := &ResumeState{
+		URL:          Link,
+		ETag:         head.Header.Get("ETag"),
+		LastModified: head.Header.Get("Last-Modified"),
+		Size:         size,
+	}
+
+	// Reuse the control file of an interrupted run when it is for the same
+	// resource, under whichever file.1 style name that run ended up with
+	var OutputFile *os.File
+	partial := partialFor(filename, Link)
+	if old := loadResumeState(partial); old != nil && old.matches(state) {
+		OutputFile, err = os.OpenFile(partName(partial), os.O_WRONLY, 0o644)
+		if err == nil {
+			filename = partial
+			state.Segments = old.Segments
+			logOrPrint(logger, c.Background, "Resuming the missing segments of an earlier download.\n")
+		}
+	}
+	if OutputFile == nil {
+		OutputFile, filename, err = createPart(filename, Overide || c.Timestamping)
+		if err != nil {
+			return "", true, err
+		}
+		state.Segments = splitSegments(size, c.Segments)
+	}
+	defer OutputFile.Close()
+
+	// Preallocate so every segment can write at its own offset
+	if err := OutputFile.Truncate(size); err != nil {
+		return filename, true, fmt.Errorf("failed to preallocate file: %v", err)
+	}
+	if err := saveResumeState(filename, state); err != nil {
+		return filename, true, fmt.Errorf("failed to save resume state: %v", err)
+	}
+
+	logOrPrint(logger, c.Background, fmt.Sprintf("Saving to: '%s' using %d connections\n", filepath.Base(filename), len(state.Segments)))
+
+	rate, err := parseRateLimit(c.RateLimite)
+	if err != nil {
+		return filename, true, err
+	}
+	// Every connection gets its share of the rate limit
+	segmentRate := rate / int64(len(state.Segments))
+	if rate > 0 && segmentRate == 0 {
+		segmentRate = 1
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := make([]error, len(state.Segments))
+	startTime := time.Now()
+	var alreadyDone int64
+	for _, seg := range state.Segments {
+		alreadyDone += seg.Done
+	}
+
+	for i := range state.Segments {
+		if state.Segments[i].complete() {
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = fetchSegment(Link, c, state, i, OutputFile, segmentRate, &mu)
+		}(i)
+	}
+
+	// One combined progress line for all connections
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	bar := c.newProgress(Link, filename, size, logger)
+	bar.skip(alreadyDone)
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	written := func() int64 {
+		mu.Lock()
+		defer mu.Unlock()
+		var total int64
+		for _, seg := range state.Segments {
+			total += seg.Done
+		}
+		return total
+	}
+loop:
+	for {
+		select {
+		case <-finished:
+			break loop
+		case <-ticker.C:
+			bar.update(written())
+			mu.Lock()
+			saveResumeState(filename, state)
+			mu.Unlock()
+		}
+	}
+	downloaded := written()
+	bar.finish(downloaded)
+
+	var failed []error
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, fmt.Errorf("segment %d: %w", i+1, err))
+		}
+	}
+	if len(failed) > 0 {
+		saveResumeState(filename, state)
+		return filename, true, fmt.Errorf("download failed, run again to fetch the missing segments: %w", errors.Join(failed...))
+	}
+	// Segments land out of order, so the file is hashed once it is complete
+	if sum != nil {
+		got, err := hashFile(partName(filename), sum, -1)
+		if err != nil {
+			return "", true, fmt.Errorf("failed to hash '%s': %v", filename, err)
+		}
+		if !sum.matches(got) {
+			OutputFile.Close()
+			return "", true, c.rejectChecksum(filename, partName(filename), sum, got)
+		}
+		logOrPrint(logger, c.Background, fmt.Sprintf("%s checksum OK\n", sum.algo))
+	}
+	if err := commitPart(OutputFile, filename); err != nil {
+		return filename, true, err
+	}
+	removeResumeState(filename)
+	setServerMtime(filename, head.Header.Get("Last-Modified"))
+
+	duration := time.Since(startTime)
+	speed := float64(downloaded-alreadyDone) / duration.Seconds() / (1024 * 1024) // MB/s
+	logOrPrint(logger, c.Background, fmt.Sprintf("%s (%s) - '%s' saved [%d/%d]\n",
+		time.Now().Format("2006-01-02 15:04:05"),
+		formatSpeed(speed),
+		filepath.Base(filename),
+		downloaded, size))
+	c.emit(event{Event: "saved", URL: Link, Path: filename, Status: head.StatusCode, Bytes: downloaded, Total: size, Elapsed: duration.Seconds()})
+	return filename, true, nil
+}
+
+// fetchSegment downloads the remaining part of one segment and writes it in place
+func fetchSegment(Link string, c *FlagsComponents, state *ResumeState, i int, out io.WriterAt, rateLimit int64, mu *sync.Mutex) error {
+	mu.Lock()
+	seg := state.Segments[i]
+	mu.Unlock()
+
+	req, err := c.newRequest("GET", Link)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", seg.Start+seg.Done, seg.End))
+	setIfRange(req, state)
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body = c.track(fmt.Sprintf("%s (segment %d)", Link, i+1), resp.ContentLength, c.countRetrieved(c.watchSpeed(resp.Body)))
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("expected 206 Partial Content, got %s", resp.Status)
+	}
+
+	dst := io.NewOffsetWriter(out, seg.Start+seg.Done)
+	buf := make([]byte, 32*1024)
+	if rateLimit > 0 && rateLimit < int64(len(buf)) {
+		buf = make([]byte, max(rateLimit/10, 1024))
+	}
+	for {
+		readStart := time.Now()
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			// Never write past the end of the segment
+			mu.Lock()
+			left := state.Segments[i].End - state.Segments[i].Start - state.Segments[i].Done + 1
+			mu.Unlock()
+			if int64(n) > left {
+				n = int(left)
+			}
+			if _, writeErr := dst.Write(buf[:n]); writeErr != nil {
+				return writeErr
+			}
+			mu.Lock()
+			state.Segments[i].Done += int64(n)
+			mu.Unlock()
+
+			if rateLimit > 0 {
+				expected := time.Duration(float64(n) / float64(rateLimit) * float64(time.Second))
+				if actual := time.Since(readStart); actual < expected {
+					time.Sleep(expected - actual)
+				}
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				return err
+			}
+			break
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !state.Segments[i].complete() {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestSplitSegments(t *testing.T) {
+	tests := []struct {
+		size int64
+		n    int
+		want []Segment
+	}{
+		{10, 1, []Segment{{Start: 0, End: 9}}},
+		{10, 2, []Segment{{Start: 0, End: 4}, {Start: 5, End: 9}}},
+		{10, 3, []Segment{{Start: 0, End: 2}, {Start: 3, End: 5}, {Start: 6, End: 9}}},
+		{3, 5, []Segment{{Start: 0, End: 0}, {Start: 1, End: 1}, {Start: 2, End: 2}}},
+		{1, 4, []Segment{{Start: 0, End: 0}}},
+	}
+	for _, tt := range tests {
+		got := splitSegments(tt.size, tt.n)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitSegments(%d, %d) = %v, want %v", tt.size, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestAcceptsRanges(t *testing.T) {
+	tests := map[string]bool{
+		"bytes":       true,
+		"Bytes":       true,
+		" bytes ":     true,
+		"none, bytes": true,
+		"none":        false,
+		"":            false,
+		"bytes-ish":   false,
+	}
+	for value, want := range tests {
+		header := http.Header{}
+		if value != "" {
+			header.Set("Accept-Ranges", value)
+		}
+		if got := acceptsRanges(header); got != want {
+			t.Errorf("acceptsRanges(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
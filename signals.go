@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// errInterrupted is the cause of the run's context after SIGINT or SIGTERM
+var errInterrupted = errors.New("interrupted")
+
+// handleSignals stops the run on SIGINT/SIGTERM by cancelling its context,
+// so transfers end where they are and keep their partial files. A second
+// signal exits at once. statusSignals (SIGUSR1) print a status snapshot
+func (c *FlagsComponents) handleSignals(parent context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(parent)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, append([]os.Signal{os.Interrupt, syscall.SIGTERM}, statusSignals...)...)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case sig := <-signals:
+				if slices.Contains(statusSignals, sig) {
+					c.say(c.status())
+					continue
+				}
+				if ctx.Err() != nil {
+					fmt.Fprintln(os.Stderr, "\nInterrupted again, exiting now.")
+					os.Exit(130)
+				}
+				name := "SIGTERM"
+				if sig == os.Interrupt {
+					name = "SIGINT"
+				}
+				c.say(fmt.Sprintf("\n%s received, stopping. Signal again to quit at once.\n", name))
+				cancel(errInterrupted)
+			}
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		close(done)
+		cancel(nil)
+	}
+}
+
+// interrupted reports whether a signal asked the run to stop
+func (c *FlagsComponents) interrupted() bool {
+	return errors.Is(context.Cause(c.context()), errInterrupted)
+}
+
+// keptPartial tells where an interrupted download left its data
+func keptPartial(Link, filename string) error {
+	if _, err := os.Stat(partName(filename)); err != nil {
+		return fmt.Errorf("%s: %w", Link, errInterrupted)
+	}
+	return fmt.Errorf("%s: %w, partial download kept in '%s', run again with -c to resume", Link, errInterrupted, partName(filename))
+}
+
+// transfer is a response body being read, for the status snapshot
+type transfer struct {
+	link    string
+	total   int64
+	started time.Time
+
+	mu   sync.Mutex
+	done int64
+}
+
+// track registers body as an active transfer of total bytes (-1 if unknown)
+// until it is closed
+func (c *FlagsComponents) track(link string, total int64, body io.ReadCloser) io.ReadCloser {
+	t := &transfer{link: link, total: total, started: time.Now()}
+	c.transfersMu.Lock()
+	if c.transfers == nil {
+		c.transfers = make(map[*transfer]struct{})
+	}
+	c.transfers[t] = struct{}{}
+	c.transfersMu.Unlock()
+	return &trackedBody{ReadCloser: body, c: c, t: t}
+}
+
+type trackedBody struct {
+	io.ReadCloser
+	c    *FlagsComponents
+	t    *transfer
+	once sync.Once
+}
+
+func (b *trackedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.t.mu.Lock()
+	b.t.done += int64(n)
+	b.t.mu.Unlock()
+	return n, err
+}
+
+func (b *trackedBody) Close() error {
+	b.once.Do(func() {
+		b.c.transfersMu.Lock()
+		delete(b.c.transfers, b.t)
+		b.c.transfersMu.Unlock()
+	})
+	return b.ReadCloser.Close()
+}
+
+// status is the snapshot printed on SIGUSR1: what is being fetched, how far
+// along it is and how much is still waiting
+func (c *FlagsComponents) status() string {
+	c.transfersMu.Lock()
+	active := make([]*transfer, 0, len(c.transfers))
+	for t := range c.transfers {
+		active = append(active, t)
+	}
+	c.transfersMu.Unlock()
+	sort.Slice(active, func(i, j int) bool { return active[i].started.Before(active[j].started) })
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\nStatus at %s: %d active, %d queued, %s retrieved",
+		time.Now().Format("2006-01-02 15:04:05"), len(active), c.pending.Load(), humanSize(float64(c.retrieved.Load())))
+	if c.isMirror {
+		c.visitedMu.RLock()
+		fmt.Fprintf(&b, ", %d pages seen", len(c.visited))
+		c.visitedMu.RUnlock()
+	}
+	b.WriteString("\n")
+	for _, t := range active {
+		t.mu.Lock()
+		done := t.done
+		t.mu.Unlock()
+		if t.total > 0 {
+			fmt.Fprintf(&b, "  %s  %s of %s (%.0f%%)\n", t.link, humanSize(float64(done)), humanSize(float64(t.total)), float64(done)*100/float64(t.total))
+		} else {
+			fmt.Fprintf(&b, "  %s  %s\n", t.link, humanSize(float64(done)))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,11 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// statusSignals ask for a status snapshot, kill -USR1 <pid>
+var statusSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// spider checks that Link resolves without saving anything. HEAD comes
+// first, servers that refuse it get a GET whose body is never read
+func (c *FlagsComponents) spider(Link string, logger *log.Logger) error {
+	say := func(msg string) { logOrPrint(logger, c.Background, msg) }
+
+	err := c.retry(Link, say, func() error {
+		say(fmt.Sprintf("--%s--  %s\n", time.Now().Format("2006-01-02 15:04:05"), Link))
+		c.emit(event{Event: "start", URL: Link})
+		response, err := c.spiderRequest("HEAD", Link)
+		if err != nil {
+			return err
+		}
+		say(fmt.Sprintf("HEAD request sent, awaiting response... %s\n", response.Status))
+		c.emit(event{Event: "response", URL: Link, Status: response.StatusCode, Total: response.ContentLength})
+		if response.StatusCode >= 400 {
+			response, err = c.spiderRequest("GET", Link)
+			if err != nil {
+				return err
+			}
+			say(fmt.Sprintf("GET request sent, awaiting response... %s\n", response.Status))
+			c.emit(event{Event: "response", URL: Link, Status: response.StatusCode, Total: response.ContentLength})
+		}
+		if response.StatusCode >= 400 {
+			say("Remote file does not exist -- broken link!!!\n\n")
+			return newHTTPStatusError(response)
+		}
+
+		if final := response.Request.URL.String(); final != Link {
+			say(fmt.Sprintf("Redirected to: %s\n", final))
+		}
+		contentType := response.Header.Get("Content-Type")
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		if response.ContentLength >= 0 {
+			say(fmt.Sprintf("Length: %d (%s) [%s]\n", response.ContentLength, humanSize(float64(response.ContentLength)), contentType))
+		} else {
+			say(fmt.Sprintf("Length: unspecified [%s]\n", contentType))
+		}
+		say("Remote file exists.\n\n")
+		c.record(event{Event: "checked", URL: Link, Status: response.StatusCode, Total: response.ContentLength})
+		return nil
+	})
+	if err != nil {
+		c.emit(event{Event: "error", URL: Link, Error: err.Error()})
+	}
+	return err
+}
+
+// spiderRequest sends one request and drops the body unread
+func (c *FlagsComponents) spiderRequest(method, Link string) (*http.Response, error) {
+	request, err := c.newRequest(method, Link)
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.Client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	response.Body.Close()
+	return response, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"time"
+)
+
+// localCopy returns the existing file -N compares against, nil if there is none
+func localCopy(filename string) os.FileInfo {
+	info, err := os.Stat(filename)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+	return info
+}
+
+// upToDate reports whether the local copy is as new as the server's and the
+// same size, for servers that ignore If-Modified-Since and for HEAD checks
+func upToDate(header http.Header, size int64, local os.FileInfo) bool {
+	modified, err := http.ParseTime(header.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	if size >= 0 && size != local.Size() {
+		return false
+	}
+	return !modified.After(local.ModTime())
+}
+
+// setServerMtime stamps filename with the server's Last-Modified so the next
+// -N run can compare against it
+func setServerMtime(filename string, lastModified string) {
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return
+	}
+	os.Chtimes(filename, time.Now(), modified)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var tlsVersions = map[string]uint16{
+	"auto":    0,
+	"TLSv1_2": tls.VersionTLS12,
+	"TLSv1_3": tls.VersionTLS13,
+}
+
+// parseSecureProtocol reads --secure-protocol, 0 leaves the choice to Go
+func parseSecureProtocol(value string) (uint16, error) {
+	for name, version := range tlsVersions {
+		if strings.EqualFold(name, value) {
+			return version, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid --secure-protocol %q, expected auto, TLSv1_2 or TLSv1_3", value)
+}
+
+// parsePinnedPubKey reads --pinnedpubkey=sha256//<base64>[;sha256//<base64>...],
+// the curl format for hashes of the server's public key
+func parsePinnedPubKey(value string) ([][]byte, error) {
+	var pins [][]byte
+	for _, pin := range strings.Split(value, ";") {
+		encoded, ok := strings.CutPrefix(strings.TrimSpace(pin), "sha256//")
+		if !ok {
+			return nil, fmt.Errorf("invalid pinned public key %q, expected sha256//<base64>", pin)
+		}
+		sum, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("invalid pinned public key %q, expected sha256//<base64>", pin)
+		}
+		pins = append(pins, sum)
+	}
+	return pins, nil
+}
+
+// newTLSConfig builds the one tls.Config shared by single downloads and the mirror
+func (c *FlagsComponents) newTLSConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         c.SecureProtocol,
+		MaxVersion:         c.SecureProtocol,
+		InsecureSkipVerify: c.NoCheckCertificate,
+	}
+
+	if c.CACertificate != "" || c.CADirectory != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if c.CACertificate != "" {
+			if err := addCAFile(pool, c.CACertificate); err != nil {
+				return nil, err
+			}
+		}
+		if c.CADirectory != "" {
+			entries, err := os.ReadDir(c.CADirectory)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read CA directory: %v", err)
+			}
+			// Anything that isn't PEM, like OpenSSL's hash symlinks to the
+			// same files, is skipped
+			for _, entry := range entries {
+				if !entry.IsDir() {
+					addCAFile(pool, filepath.Join(c.CADirectory, entry.Name()))
+				}
+			}
+		}
+		config.RootCAs = pool
+	}
+
+	if c.Certificate != "" {
+		// The key may live in the certificate file, as with wget
+		key := c.PrivateKey
+		if key == "" {
+			key = c.Certificate
+		}
+		cert, err := tls.LoadX509KeyPair(c.Certificate, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	// Pins are checked even with --no-check-certificate, that is how a
+	// self-signed server can still be trusted
+	if len(c.PinnedPubKey) > 0 {
+		pins := c.PinnedPubKey
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return fmt.Errorf("no server certificate to check the pinned public key against")
+			}
+			sum := sha256.Sum256(state.PeerCertificates[0].RawSubjectPublicKeyInfo)
+			for _, pin := range pins {
+				if bytes.Equal(pin, sum[:]) {
+					return nil
+				}
+			}
+			return fmt.Errorf("server public key sha256//%s doesn't match --pinnedpubkey", base64.StdEncoding.EncodeToString(sum[:]))
+		}
+	}
+	return config, nil
+}
+
+func addCAFile(pool *x509.CertPool, filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read CA certificate: %v", err)
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("no certificates found in %s", filename)
+	}
+	return nil
+}
+
+// describeTLS is the log line for an HTTPS connection
+func describeTLS(state *tls.ConnectionState) string {
+	line := tls.VersionName(state.Version)
+	if len(state.PeerCertificates) > 0 {
+		line += fmt.Sprintf(", certificate subject: %s", state.PeerCertificates[0].Subject)
+	}
+	return line + "\n"
+}
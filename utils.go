@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
 	"slices"
@@ -16,6 +17,31 @@ func CheckValidFlag(f string, flags []string) bool {
 	return slices.Contains(flags, f)
 }
 
+// flagName strips the =value part off an argument
+func flagName(arg string) string {
+	name, _, _ := strings.Cut(arg, "=")
+	return name
+}
+
+// CatchValue reads the value of a flag written either as flag=value or as
+// the next argument, next tells the caller to skip that argument
+func CatchValue(args []string, flags []string) (string, bool, error) {
+	name, value, hasValue := strings.Cut(args[0], "=")
+	if !CheckValidFlag(name, flags) {
+		return "", false, fmt.Errorf("invalid flag %s", name)
+	}
+	if hasValue {
+		if value == "" {
+			return "", false, fmt.Errorf("missing value while presence of the %s flag", name)
+		}
+		return value, false, nil
+	}
+	if len(args) < 2 || args[1] == "" {
+		return "", false, fmt.Errorf("missing value while presence of the %s flag", name)
+	}
+	return args[1], true, nil
+}
+
 func CatchOutputFile(args []string, comp *FlagsComponents, flags []string) (bool, error) {
 	if strings.Contains(args[0], "=") {
 		sli := strings.Split(args[0], "=")
@@ -223,6 +249,89 @@ func (c *FlagsComponents) Validate() error {
 		return fmt.Errorf("cannot use -O (output file) with --mirror")
 	}
 
+	if c.Timestamping && c.OutputFile != "" {
+		return fmt.Errorf("cannot use -N (timestamping) with -O (output file)")
+	}
+
+	if c.isMirror && c.InputFile != "" {
+		return fmt.Errorf("cannot use -i (batch download) with --mirror")
+	}
+
+	if c.Jobs > 1 && c.InputFile == "" {
+		return fmt.Errorf("-j only applies to -i (batch download)")
+	}
+
+	if c.Checksum != nil && (len(c.Links) > 1 || c.InputFile != "") {
+		return fmt.Errorf("--checksum=<algo>:<hex> can only verify a single URL, use --checksum-url or --checksum=auto")
+	}
+	if (c.Checksum != nil || c.ChecksumURL != "" || c.ChecksumAuto) && c.isMirror {
+		return fmt.Errorf("cannot use --checksum with --mirror")
+	}
+
+	if c.PrivateKey != "" && c.Certificate == "" {
+		return fmt.Errorf("--private-key requires --certificate")
+	}
+
+	if c.NoProxy && c.Proxy != "" {
+		return fmt.Errorf("cannot use --no-proxy with --proxy")
+	}
+
+	if c.toStdout() && (c.Continue || c.Segments > 1) {
+		return fmt.Errorf("cannot use -c or --segments with -O -")
+	}
+	if c.concatenating() && (c.Continue || c.Segments > 1) {
+		return fmt.Errorf("cannot use -c or --segments when several URLs go into one -O file")
+	}
+
+	if c.Spider && (c.isMirror || c.OutputFile != "") {
+		return fmt.Errorf("cannot use --spider with --mirror or -O, it saves nothing")
+	}
+
+	if c.SaveHeaders && (c.Continue || c.Segments > 1 || c.toStdout()) {
+		return fmt.Errorf("cannot use --save-headers with -c, --segments or -O -")
+	}
+
+	if (c.KeepEncoded || c.MaxDecompressedSize > 0) && c.Compression != "auto" && c.Compression != "gzip" {
+		return fmt.Errorf("--keep-encoded and --max-decompressed-size need --compression=auto or gzip")
+	}
+
+	if c.Background && (c.toStdout() || c.InputFile == "-" || c.AskPassword) {
+		return fmt.Errorf("cannot use -B with -O -, -i - or --ask-password, the background process has no terminal")
+	}
+
+	if c.EventsFD == 1 && c.toStdout() {
+		return fmt.Errorf("cannot use --events=json with -O -, stdout carries the download, use --events-fd")
+	}
+	if c.EventsFD > 0 && c.Background {
+		return fmt.Errorf("cannot use --events or --events-fd with -B, the background process keeps no open descriptors")
+	}
+
+	if c.isMirror && c.Segments > 1 {
+		return fmt.Errorf("cannot use --segments with --mirror")
+	}
+
+	// Request body options
+	if c.PostData != "" && c.PostFile != "" {
+		return fmt.Errorf("cannot use both --post-data and --post-file")
+	}
+	if c.BodyData != "" && (c.PostData != "" || c.PostFile != "") {
+		return fmt.Errorf("cannot use --body-data with --post-data or --post-file")
+	}
+	if c.BodyData != "" && c.Method == "" {
+		return fmt.Errorf("--body-data requires --method")
+	}
+	if c.AskPassword && (c.Password != "" || c.HTTPPassword != "") {
+		return fmt.Errorf("cannot use --ask-password with --password or --http-password")
+	}
+
+	if c.NoCookies && (c.LoadCookies != "" || c.SaveCookies != "") {
+		return fmt.Errorf("cannot use --no-cookies with --load-cookies or --save-cookies")
+	}
+
+	if c.Segments > 1 && c.requestMethod() != "GET" {
+		return fmt.Errorf("--segments can only be used with GET requests")
+	}
+
 	// Mirror-specific validations
 	if (len(c.Reject) > 0 || len(c.Exclude) > 0 || c.Convert) && !c.isMirror {
 		return fmt.Errorf("-R, -X, and --convert-links can only be used with --mirror")
@@ -236,11 +345,16 @@ func (c *FlagsComponents) Validate() error {
 	return nil
 }
 
+// say logs a message for code that has no logger of its own to pass around
+func (c *FlagsComponents) say(message string) {
+	logOrPrint(c.logger, c.Background, message)
+}
+
 func logOrPrint(logger *log.Logger, background bool, message string) {
 	if background {
 		logger.Printf("%s", message)
 	} else {
-		fmt.Printf("%s", message)
+		fmt.Fprintf(Stdout, "%s", message)
 	}
 }
 
@@ -273,6 +387,24 @@ func parseRateLimit(rateLimitStr string) (int64, error) {
 	return value * multiplier, nil
 }
 
+// parseSeconds reads a wget style duration, plain seconds like "10" or
+// "0.5", or a Go duration like "1m30s"
+func parseSeconds(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if secs, err := strconv.ParseFloat(value, 64); err == nil && secs >= 0 {
+		// Inf and huge values would wrap around to a negative Duration
+		if secs > float64(math.MaxInt64/time.Second) {
+			return 0, fmt.Errorf("invalid duration: %s", value)
+		}
+		return time.Duration(secs * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration: %s", value)
+	}
+	return d, nil
+}
+
 func formatSpeed(speedMBps float64) string {
 	// // Cap extremely high speeds to avoid scientific notation
 	// if speedMBps > 999.99 {
@@ -292,51 +424,38 @@ func formatSpeed(speedMBps float64) string {
 }
 
 func Create_Output_file(Overide bool, filename string) (*os.File, error) {
-	var out *os.File
-	var err error
-	if Overide {
-		out, err = os.Create(filename)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create file: %v", err)
-		}
-	} else {
-		_, err := os.Stat(filename)
-		if err != nil {
-			if os.IsNotExist(err) {
-				out, err = os.Create(filename)
-				if err != nil {
-					return nil, fmt.Errorf("failed to create file: %v", err)
-				}
-			} else {
-				return nil, err
-			}
+	if !Overide {
+		claimedMu.Lock()
+		filename = freeName(filename)
+		claimedMu.Unlock()
+	}
+	out, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %v", err)
+	}
+	return out, nil
+}
+
+// freeName returns filename, or the first of file.1, file.2... that is free
+func freeName(filename string) string {
+	if nameFree(filename) {
+		return filename
+	}
+	// File exists - create with number suffix
+	dir := filepath.Dir(filename)
+	ext := filepath.Ext(filename)
+	base := strings.TrimSuffix(filepath.Base(filename), ext)
+	for i := 1; ; i++ {
+		if ext != "" {
+			filename = filepath.Join(dir, fmt.Sprintf("%s.%d%s", base, i, ext))
 		} else {
-			// File exists - create with number suffix
-			dir := filepath.Dir(filename)
-			ext := filepath.Ext(filename)
-			base := strings.TrimSuffix(filepath.Base(filename), ext)
-			i := 1
-			for {
-				if ext != "" {
-					filename = filepath.Join(dir, fmt.Sprintf("%s.%d%s", base, i, ext))
-				} else {
-					filename = filepath.Join(dir, fmt.Sprintf("%s.%d", base, i))
-				}
-
-				// Check if this numbered version exists
-				if _, err := os.Stat(filename); os.IsNotExist(err) {
-					// This name is available
-					out, err = os.Create(filename)
-					if err != nil {
-						return nil, fmt.Errorf("failed to create file: %v", err)
-					}
-					break
-				}
-				i += 1
-			}
+			filename = filepath.Join(dir, fmt.Sprintf("%s.%d", base, i))
+		}
+		// Check if this numbered version exists
+		if nameFree(filename) {
+			return filename
 		}
 	}
-	return out, nil
 }
 
 func formatETA(d time.Duration) string {
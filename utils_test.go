@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSeconds(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"30", 30 * time.Second, true},
+		{" 10 ", 10 * time.Second, true},
+		{"1.5", 1500 * time.Millisecond, true},
+		{"0", 0, true},
+		{"2m", 2 * time.Minute, true},
+		{"1h30m", 90 * time.Minute, true},
+		{"250ms", 250 * time.Millisecond, true},
+		{"-1", 0, false},
+		{"-5s", 0, false},
+		{"soon", 0, false},
+		{"", 0, false},
+		{"NaN", 0, false},
+		{"Inf", 0, false},
+		{"1e30", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseSeconds(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseSeconds(%q) = %s, %v, want %s, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}